@@ -7,7 +7,9 @@ import (
 
 	"github.com/alecthomas/kong"
 	"github.com/havocked/bahn-cli/internal/app"
+	"github.com/havocked/bahn-cli/internal/auth"
 	"github.com/havocked/bahn-cli/internal/cli"
+	"github.com/havocked/bahn-cli/internal/output"
 )
 
 var exitFunc = os.Exit
@@ -38,20 +40,50 @@ func run(args []string, out io.Writer, errOut io.Writer) int {
 	if exitCode >= 0 {
 		return exitCode
 	}
+	settings := command.Globals.Settings()
 	if err != nil {
-		_, _ = fmt.Fprintln(errOut, err)
-		return 2
+		return report(fallbackWriter(settings, out, errOut), app.NewError(app.CodeInvalidInput, err))
 	}
 
-	ctx, err := app.NewContext(command.Globals.Settings())
+	ctx, err := app.NewContext(settings)
 	if err != nil {
-		_, _ = fmt.Fprintln(errOut, err)
-		return 1
+		return report(fallbackWriter(settings, out, errOut), err)
+	}
+	if err := auth.Configure(ctx.Config.Auth, ctx.Settings.Account); err != nil {
+		return report(ctx.Output, err)
 	}
 
+	ctx.Output.SetCommand(kctx.Selected().Path())
 	if err := kctx.Run(ctx); err != nil {
-		ctx.Output.Errorf("%v", err)
-		return app.ExitCode(err)
+		return report(ctx.Output, err)
 	}
 	return 0
 }
+
+// fallbackWriter is the output writer for errors raised before a Context
+// exists. An unusable format falls back to JSON.
+func fallbackWriter(settings app.Settings, out, errOut io.Writer) *output.Writer {
+	format, err := app.ResolveFormat(settings.Format, nil)
+	if err != nil {
+		format = output.FormatJSON
+	}
+	return output.New(output.Options{
+		Format:   format,
+		Out:      out,
+		Err:      errOut,
+		Quiet:    settings.Quiet,
+		Envelope: settings.Envelope && (format == output.FormatJSON || format == output.FormatNDJSON),
+		Version:  settings.Version,
+	})
+}
+
+// report writes err to stderr and, in JSON mode, as the structured error
+// on stdout. It returns the exit code.
+func report(w *output.Writer, err error) int {
+	w.Errorf("%v", err)
+	if w.Format != output.FormatHuman && !app.IsReported(err) {
+		code, message, action := app.Describe(err)
+		_ = w.ErrorJSON(string(code), message, action)
+	}
+	return app.ExitCode(err)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestRunParseError(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if code := run([]string{"--bogus"}, &out, &errOut); code != 1 {
+		t.Errorf("exit = %d, want 1", code)
+	}
+	var got struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("stdout is not JSON: %v\n%s", err, out.String())
+	}
+	if got.Error != "invalid_input" || got.Message != "unknown flag --bogus" {
+		t.Errorf("error = %+v", got)
+	}
+	if errOut.Len() == 0 {
+		t.Error("nothing on stderr")
+	}
+}
+
+func TestRunFormatIsGlobal(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if code := run([]string{"--format", "header", "auth", "print-token"}, &out, &errOut); code != 1 {
+		t.Errorf("exit = %d, want 1", code)
+	}
+	var got struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("stdout is not JSON: %v\n%s", err, out.String())
+	}
+	if got.Error != "invalid_input" || got.Message != `unknown output format "header" (want json, human, ndjson, csv or tsv)` {
+		t.Errorf("error = %+v", got)
+	}
+}
@@ -1,6 +1,10 @@
 package app
 
 import (
+	"fmt"
+	"os"
+	"strconv"
+
 	"github.com/havocked/bahn-cli/internal/config"
 	"github.com/havocked/bahn-cli/internal/output"
 )
@@ -12,6 +16,10 @@ type Settings struct {
 	Quiet      bool
 	Verbose    bool
 	APIKey     string
+	Account    string
+	Fields     []string
+	Envelope   bool
+	Version    string
 }
 
 // Context holds runtime state shared across commands.
@@ -33,9 +41,21 @@ func NewContext(settings Settings) (*Context, error) {
 		configPath, _ = config.DefaultPath()
 	}
 
+	format, err := ResolveFormat(settings.Format, cfg)
+	if err != nil {
+		return nil, NewError(CodeInvalidInput, err)
+	}
+	settings.Format = format
+	if settings.Envelope && format != output.FormatJSON && format != output.FormatNDJSON {
+		return nil, Errorf(CodeInvalidInput, "--envelope needs json or ndjson output, not %s", format)
+	}
+
 	w := output.New(output.Options{
-		Format: settings.Format,
-		Quiet:  settings.Quiet,
+		Format:   format,
+		Quiet:    settings.Quiet,
+		Fields:   settings.Fields,
+		Envelope: settings.Envelope,
+		Version:  settings.Version,
 	})
 
 	return &Context{
@@ -45,3 +65,42 @@ func NewContext(settings Settings) (*Context, error) {
 		Output:     w,
 	}, nil
 }
+
+// Environment variables that select the output format when neither
+// --format nor --human is given.
+const (
+	EnvFormat = "BAHN_FORMAT"
+	EnvHuman  = "BAHN_HUMAN"
+)
+
+// ResolveFormat picks the output format: flag, then BAHN_FORMAT, then
+// BAHN_HUMAN, then [output] format from the config file, then JSON.
+func ResolveFormat(flag output.Format, cfg *config.Config) (output.Format, error) {
+	if flag != "" {
+		return output.ParseFormat(string(flag))
+	}
+	if env := os.Getenv(EnvFormat); env != "" {
+		f, err := output.ParseFormat(env)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", EnvFormat, err)
+		}
+		return f, nil
+	}
+	if env := os.Getenv(EnvHuman); env != "" {
+		human, err := strconv.ParseBool(env)
+		if err != nil {
+			return "", fmt.Errorf("%s: want true or false, got %q", EnvHuman, env)
+		}
+		if human {
+			return output.FormatHuman, nil
+		}
+	}
+	if cfg != nil && cfg.Output.Format != "" {
+		f, err := output.ParseFormat(cfg.Output.Format)
+		if err != nil {
+			return "", fmt.Errorf("config [output] format: %w", err)
+		}
+		return f, nil
+	}
+	return output.FormatJSON, nil
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/havocked/bahn-cli/internal/config"
+	"github.com/havocked/bahn-cli/internal/output"
+)
+
+func TestResolveFormat(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Output.Format = "tsv"
+	tests := []struct {
+		name          string
+		flag          output.Format
+		format, human string
+		want          output.Format
+	}{
+		{"flag wins", output.FormatCSV, "ndjson", "1", output.FormatCSV},
+		{"BAHN_FORMAT over BAHN_HUMAN", "", "csv", "1", output.FormatCSV},
+		{"BAHN_HUMAN over config", "", "", "true", output.FormatHuman},
+		{"BAHN_HUMAN false", "", "", "0", output.FormatTSV},
+		{"config", "", "", "", output.FormatTSV},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvFormat, tt.format)
+			t.Setenv(EnvHuman, tt.human)
+			got, err := ResolveFormat(tt.flag, cfg)
+			if err != nil || got != tt.want {
+				t.Errorf("ResolveFormat = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -2,7 +2,9 @@ package app
 
 import (
 	"errors"
+	"fmt"
 	"net"
+	"net/http"
 )
 
 // Exit codes:
@@ -35,6 +37,143 @@ func WrapExit(code int, err error) error {
 	return ExitError{Code: code, Err: err}
 }
 
+// Code is the machine-readable "error" field of the output contract.
+type Code string
+
+const (
+	CodeGeneral        Code = "error"
+	CodeAuthRequired   Code = "auth_required"
+	CodeTokenExpired   Code = "token_expired"
+	CodeSessionExpired Code = "session_expired"
+	CodeInvalidToken   Code = "invalid_token"
+	CodeNetwork        Code = "network"
+	CodeNotFound       Code = "not_found"
+	CodeRateLimited    Code = "rate_limited"
+	CodeInvalidInput   Code = "invalid_input"
+)
+
+type codeInfo struct {
+	exit   int
+	action string
+}
+
+var codes = map[Code]codeInfo{
+	CodeGeneral:        {1, ""},
+	CodeAuthRequired:   {2, "run `bahn auth login` or `bahn auth token <jwt>`"},
+	CodeTokenExpired:   {2, "run `bahn auth refresh` or `bahn auth login`"},
+	CodeSessionExpired: {2, "run `bahn auth login`"},
+	CodeInvalidToken:   {2, "copy a fresh token from bahn.de or run `bahn auth login`"},
+	CodeNetwork:        {3, "check network connectivity and retry"},
+	CodeNotFound:       {4, "check the identifier and retry"},
+	CodeRateLimited:    {1, "wait a minute before retrying"},
+	CodeInvalidInput:   {1, "fix the arguments; see `bahn --help`"},
+}
+
+// Error is a failure with a code from the taxonomy above.
+// Action overrides the code's default recovery text.
+type Error struct {
+	Code   Code
+	Err    error
+	Action string
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return string(e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewError tags err with code. It returns nil for a nil err.
+func NewError(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// Errorf is NewError with a formatted message.
+func Errorf(code Code, format string, args ...any) error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// StatusError classifies an unexpected HTTP status from an API call.
+func StatusError(status int, err error) error {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return NewError(CodeAuthRequired, err)
+	case status == http.StatusNotFound:
+		return NewError(CodeNotFound, err)
+	case status == http.StatusTooManyRequests:
+		return NewError(CodeRateLimited, err)
+	case status >= 500:
+		return NewError(CodeNetwork, err)
+	}
+	return err
+}
+
+// reportedError marks an error whose outcome is already on stdout.
+type reportedError struct{ error }
+
+func (e reportedError) Unwrap() error { return e.error }
+
+// Reported marks err as already reported on stdout (e.g. a payload with
+// ok=false), so main only sets the exit code.
+func Reported(err error) error {
+	if err == nil {
+		return nil
+	}
+	return reportedError{err}
+}
+
+// IsReported reports whether err was marked with Reported.
+func IsReported(err error) bool {
+	var r reportedError
+	return errors.As(err, &r)
+}
+
+// Action returns the default recovery action for code.
+func Action(code Code) string {
+	return codes[code].action
+}
+
+// Describe returns the code, message and recovery action for err.
+func Describe(err error) (Code, string, string) {
+	code := classify(err)
+	action := Action(code)
+	var appErr *Error
+	if errors.As(err, &appErr) && appErr.Action != "" {
+		action = appErr.Action
+	}
+	return code, err.Error(), action
+}
+
+func classify(err error) Code {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	var exitErr ExitError
+	if errors.As(err, &exitErr) {
+		switch exitErr.Code {
+		case 2:
+			return CodeAuthRequired
+		case 3:
+			return CodeNetwork
+		case 4:
+			return CodeNotFound
+		}
+	}
+	if isNetErr(err) {
+		return CodeNetwork
+	}
+	return CodeGeneral
+}
+
 func ExitCode(err error) int {
 	if err == nil {
 		return 0
@@ -43,6 +182,12 @@ func ExitCode(err error) int {
 	if errors.As(err, &exitErr) && exitErr.Code != 0 {
 		return exitErr.Code
 	}
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		if info, ok := codes[appErr.Code]; ok {
+			return info.exit
+		}
+	}
 	if isNetErr(err) {
 		return 3
 	}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	"github.com/havocked/bahn-cli/internal/app"
+	"github.com/havocked/bahn-cli/internal/config"
+)
+
+// DefaultAccount is used when no account is selected. Its tokens live in
+// the original tokens.json location.
+const DefaultAccount = "default"
+
+var accountNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// Account describes a named login.
+type Account struct {
+	Name    string
+	Current bool
+	Tokens  *TokenSet // nil if nothing is stored
+	Err     error     // set if the tokens could not be read
+}
+
+// accountIndex is ~/.config/bahn-cli/accounts.json. The keyring cannot be
+// enumerated, so known account names are tracked here.
+type accountIndex struct {
+	Current  string   `json:"current,omitempty"`
+	Accounts []string `json:"accounts"`
+}
+
+func validateAccountName(name string) error {
+	if !accountNameRe.MatchString(name) {
+		return app.Errorf(app.CodeInvalidInput, "invalid account name %q (letters, digits, '-' and '_' only)", name)
+	}
+	return nil
+}
+
+// ActiveAccount returns the account selected with --account, else the one
+// chosen with `bahn auth accounts use`, else DefaultAccount.
+func ActiveAccount() string {
+	storeMu.Lock()
+	selected := selectedAccount
+	storeMu.Unlock()
+	if selected != "" {
+		return selected
+	}
+	if idx, err := loadIndex(); err == nil && idx.Current != "" {
+		return idx.Current
+	}
+	return DefaultAccount
+}
+
+// Accounts lists every known account with its stored tokens.
+func Accounts() ([]Account, error) {
+	idx, err := loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	active := ActiveAccount()
+
+	names := slices.Clone(idx.Accounts)
+	// tokens.json from before accounts existed is not in the index.
+	if !slices.Contains(names, DefaultAccount) {
+		if tokens, _ := storeFor(DefaultAccount).Load(); tokens != nil {
+			names = append([]string{DefaultAccount}, names...)
+		}
+	}
+
+	accounts := make([]Account, 0, len(names))
+	for _, name := range names {
+		tokens, err := storeFor(name).Load()
+		accounts = append(accounts, Account{
+			Name:    name,
+			Current: name == active,
+			Tokens:  tokens,
+			Err:     err,
+		})
+	}
+	return accounts, nil
+}
+
+// UseAccount makes name the current account for future invocations.
+func UseAccount(name string) error {
+	if err := validateAccountName(name); err != nil {
+		return err
+	}
+	return updateIndex(func(idx *accountIndex) error {
+		if name != DefaultAccount && !slices.Contains(idx.Accounts, name) {
+			return fmt.Errorf("unknown account %q — run `bahn --account %s auth login` first", name, name)
+		}
+		idx.Current = name
+		if name == DefaultAccount {
+			idx.Current = ""
+		}
+		return nil
+	})
+}
+
+// RemoveAccount deletes an account's tokens and forgets it.
+func RemoveAccount(name string) error {
+	if err := validateAccountName(name); err != nil {
+		return err
+	}
+	if err := storeFor(name).Clear(); err != nil {
+		return err
+	}
+	return updateIndex(func(idx *accountIndex) error {
+		idx.Accounts = slices.DeleteFunc(idx.Accounts, func(n string) bool { return n == name })
+		if idx.Current == name {
+			idx.Current = ""
+		}
+		return nil
+	})
+}
+
+// registerAccount adds name to the index if missing.
+func registerAccount(name string) error {
+	if idx, err := loadIndex(); err == nil && slices.Contains(idx.Accounts, name) {
+		return nil
+	}
+	return updateIndex(func(idx *accountIndex) error {
+		if !slices.Contains(idx.Accounts, name) {
+			idx.Accounts = append(idx.Accounts, name)
+		}
+		return nil
+	})
+}
+
+// updateIndex applies fn to the index under a lock on accounts.json.lock,
+// so concurrent processes do not lose each other's changes. The index is
+// not written if fn fails.
+func updateIndex(fn func(idx *accountIndex) error) error {
+	path, err := indexPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return fmt.Errorf("locking account index: %w", err)
+	}
+	defer unlock()
+
+	idx, err := loadIndex()
+	if err != nil {
+		return err
+	}
+	if err := fn(idx); err != nil {
+		return err
+	}
+	return saveIndex(idx)
+}
+
+func indexPath() (string, error) {
+	dir, err := config.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "accounts.json"), nil
+}
+
+func loadIndex() (*accountIndex, error) {
+	path, err := indexPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &accountIndex{}, nil
+		}
+		return nil, err
+	}
+	var idx accountIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &idx, nil
+}
+
+func saveIndex(idx *accountIndex) error {
+	path, err := indexPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0o600)
+}
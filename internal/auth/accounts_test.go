@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/havocked/bahn-cli/internal/app"
+	"github.com/havocked/bahn-cli/internal/config"
+)
+
+// selectAccount acts like --account name for the duration of the test.
+func selectAccount(t *testing.T, name string) {
+	t.Helper()
+	storeMu.Lock()
+	selectedAccount = name
+	storeMu.Unlock()
+	t.Cleanup(func() {
+		storeMu.Lock()
+		selectedAccount = ""
+		storeMu.Unlock()
+	})
+}
+
+func accountNames(t *testing.T) []string {
+	t.Helper()
+	accounts, err := Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, acc := range accounts {
+		names = append(names, acc.Name)
+	}
+	return names
+}
+
+func TestSaveTokensRegistersAccount(t *testing.T) {
+	useTempStore(t)
+	selectAccount(t, "work")
+	if err := SaveTokens(testTokens("work-token")); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts, err := Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].Name != "work" || !accounts[0].Current {
+		t.Fatalf("accounts = %+v, want only work, current", accounts)
+	}
+	if accounts[0].Tokens == nil || accounts[0].Tokens.AccessToken != "work-token" {
+		t.Errorf("work tokens = %+v", accounts[0].Tokens)
+	}
+	idx, err := loadIndex()
+	if err != nil || !slices.Equal(idx.Accounts, []string{"work"}) {
+		t.Errorf("index = %+v, %v", idx, err)
+	}
+}
+
+func TestUseAccount(t *testing.T) {
+	useTempStore(t)
+	selectAccount(t, "work")
+	if err := SaveTokens(testTokens("work-token")); err != nil {
+		t.Fatal(err)
+	}
+	selectAccount(t, "")
+
+	if err := UseAccount("private"); err == nil || !strings.Contains(err.Error(), "unknown account") {
+		t.Errorf("use unknown account: err = %v", err)
+	}
+	if got := ActiveAccount(); got != DefaultAccount {
+		t.Errorf("active after failed use = %q, want %q", got, DefaultAccount)
+	}
+
+	if err := UseAccount("work"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ActiveAccount(); got != "work" {
+		t.Errorf("active = %q, want work", got)
+	}
+	tokens, err := LoadTokens()
+	if err != nil || tokens == nil || tokens.AccessToken != "work-token" {
+		t.Errorf("LoadTokens = %v, %v; want work's tokens", tokens, err)
+	}
+}
+
+func TestRemoveCurrentAccount(t *testing.T) {
+	useTempStore(t)
+	for _, name := range []string{DefaultAccount, "work"} {
+		selectAccount(t, name)
+		if err := SaveTokens(testTokens(name + "-token")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	selectAccount(t, "")
+	if err := UseAccount("work"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RemoveAccount("work"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ActiveAccount(); got != DefaultAccount {
+		t.Errorf("active after removing the current account = %q, want %q", got, DefaultAccount)
+	}
+	if got := accountNames(t); !slices.Equal(got, []string{DefaultAccount}) {
+		t.Errorf("accounts = %v, want [default]", got)
+	}
+	if tokens, _ := storeFor("work").Load(); tokens != nil {
+		t.Error("work tokens still stored")
+	}
+}
+
+func TestClearTokensKeepsAccount(t *testing.T) {
+	useTempStore(t)
+	for _, name := range []string{DefaultAccount, "work"} {
+		selectAccount(t, name)
+		if err := SaveTokens(testTokens(name + "-token")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	selectAccount(t, "")
+	if err := UseAccount("work"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ClearTokens(); err != nil {
+		t.Fatal(err)
+	}
+	if got := ActiveAccount(); got != "work" {
+		t.Errorf("active after clear = %q, want work", got)
+	}
+	if got := accountNames(t); !slices.Equal(got, []string{DefaultAccount, "work"}) {
+		t.Errorf("accounts after clear = %v, want [default work]", got)
+	}
+	if tokens, _ := storeFor("work").Load(); tokens != nil {
+		t.Error("work tokens still stored")
+	}
+	if tokens, _ := storeFor(DefaultAccount).Load(); tokens == nil {
+		t.Error("default tokens cleared")
+	}
+}
+
+func TestRegisterAccountConcurrent(t *testing.T) {
+	useTempStore(t)
+	names := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := registerAccount(name); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	idx, err := loadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := slices.Sorted(slices.Values(idx.Accounts))
+	if !slices.Equal(got, names) {
+		t.Errorf("index = %v, want %v", got, names)
+	}
+}
+
+func TestLegacyTokensListedAsDefault(t *testing.T) {
+	useTempStore(t)
+	SetStoreFactory(func(account string) Store { return NewFileStore(account) })
+	// tokens.json written before accounts existed: no accounts.json.
+	if err := NewFileStore(DefaultAccount).Save(testTokens("legacy")); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts, err := Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].Name != DefaultAccount || !accounts[0].Current {
+		t.Fatalf("accounts = %+v, want default, current", accounts)
+	}
+	if accounts[0].Tokens == nil || accounts[0].Tokens.AccessToken != "legacy" {
+		t.Errorf("default tokens = %+v", accounts[0].Tokens)
+	}
+}
+
+func TestInvalidAccountNames(t *testing.T) {
+	useTempStore(t)
+	for _, name := range []string{"../work", "a b", "-work", "work/x", strings.Repeat("a", 65)} {
+		err := Configure(config.AuthConfig{}, name)
+		if code, _, _ := app.Describe(err); code != app.CodeInvalidInput {
+			t.Errorf("--account %q: code = %s, want %s (err: %v)", name, code, app.CodeInvalidInput, err)
+		}
+		if err := UseAccount(name); err == nil {
+			t.Errorf("use %q succeeded", name)
+		}
+		if err := RemoveAccount(name); err == nil {
+			t.Errorf("remove %q succeeded", name)
+		}
+	}
+	if got := ActiveAccount(); got != DefaultAccount {
+		t.Errorf("active = %q, want %q", got, DefaultAccount)
+	}
+}
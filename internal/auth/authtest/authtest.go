@@ -0,0 +1,174 @@
+// Package authtest holds the fake Keycloak realm and fixtures shared by
+// the tests of the auth package and of the commands built on it.
+package authtest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Code is the authorization code the fake realm issues.
+const Code = "fake-code"
+
+// Keycloak is an httptest Keycloak realm: OIDC discovery, an auth
+// endpoint that issues Code, a token endpoint that checks PKCE, and
+// revocation and end-session endpoints that record what they receive.
+type Keycloak struct {
+	*httptest.Server
+
+	// RejectLoopback makes /auth answer 400 for loopback redirect URIs,
+	// like the real bahn.de client does.
+	RejectLoopback bool
+	// SessionCookie is required by prompt=none requests when set.
+	SessionCookie string
+	// TokenHits counts requests to the token endpoint.
+	TokenHits atomic.Int32
+
+	mu         sync.Mutex
+	challenges map[string]string // code -> code_challenge
+	revoked    url.Values
+	endSession url.Values
+}
+
+// NewKeycloak starts a fake realm that is closed when the test ends.
+func NewKeycloak(t testing.TB) *Keycloak {
+	t.Helper()
+	kc := &Keycloak{challenges: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", kc.handleDiscovery)
+	mux.HandleFunc("/auth", kc.handleAuth)
+	mux.HandleFunc("/token", kc.handleToken)
+	mux.HandleFunc("/revoke", kc.handleRevoke)
+	mux.HandleFunc("/logout", kc.handleLogout)
+	kc.Server = httptest.NewServer(mux)
+	t.Cleanup(kc.Close)
+	return kc
+}
+
+// SetChallenge records the PKCE challenge the token endpoint expects
+// for code, as if /auth had issued it.
+func (kc *Keycloak) SetChallenge(code, challenge string) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	kc.challenges[code] = challenge
+}
+
+// Revoked returns the last form posted to the revocation endpoint.
+func (kc *Keycloak) Revoked() url.Values {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	return kc.revoked
+}
+
+// EndSession returns the query of the last end-session request.
+func (kc *Keycloak) EndSession() url.Values {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	return kc.endSession
+}
+
+func (kc *Keycloak) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 kc.URL,
+		"authorization_endpoint": kc.URL + "/auth",
+		"token_endpoint":         kc.URL + "/token",
+		"revocation_endpoint":    kc.URL + "/revoke",
+		"end_session_endpoint":   kc.URL + "/logout",
+	})
+}
+
+func (kc *Keycloak) handleAuth(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect := q.Get("redirect_uri")
+	if kc.RejectLoopback && strings.HasPrefix(redirect, "http://127.0.0.1:") {
+		http.Error(w, "Invalid parameter: redirect_uri", http.StatusBadRequest)
+		return
+	}
+	kc.SetChallenge(Code, q.Get("code_challenge"))
+
+	if q.Get("prompt") != "none" {
+		fmt.Fprint(w, "<html>login form</html>")
+		return
+	}
+	if c, err := r.Cookie("KEYCLOAK_IDENTITY"); err != nil || c.Value != kc.SessionCookie {
+		http.Redirect(w, r, redirect+"#error=login_required&state="+q.Get("state"), http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, redirect+"#state="+q.Get("state")+"&code="+Code, http.StatusFound)
+}
+
+func (kc *Keycloak) handleToken(w http.ResponseWriter, r *http.Request) {
+	kc.TokenHits.Add(1)
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" {
+		http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+		return
+	}
+	kc.mu.Lock()
+	challenge, ok := kc.challenges[r.Form.Get("code")]
+	kc.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_grant","error_description":"PKCE verification failed"}`)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"access_token": JWT(map[string]any{
+			"exp":                time.Now().Add(5 * time.Minute).Unix(),
+			"sub":                "user-1",
+			"preferred_username": "erika",
+			"realm_access":       map[string]any{"roles": []string{"bue_buchen"}},
+		}),
+		"id_token":   "id-token",
+		"token_type": "Bearer",
+		"expires_in": 300,
+	})
+}
+
+func (kc *Keycloak) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	kc.mu.Lock()
+	kc.revoked = r.PostForm
+	kc.mu.Unlock()
+}
+
+func (kc *Keycloak) handleLogout(w http.ResponseWriter, r *http.Request) {
+	kc.mu.Lock()
+	kc.endSession = r.URL.Query()
+	kc.mu.Unlock()
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// JWT returns an unsigned JWT with the given claims and a dummy
+// signature.
+func JWT(claims map[string]any) string {
+	enc := func(v any) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	return enc(map[string]string{"alg": "RS256", "typ": "JWT"}) + "." + enc(claims) + ".c2ln"
+}
+
+// UseTempConfigDir points HOME, XDG_CONFIG_HOME and AppData at a temp
+// dir for the duration of the test, so the config dir, token files,
+// account index and lock files all land there. It returns the dir.
+func UseTempConfigDir(t testing.TB) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("AppData", dir)
+	return dir
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// errRedirectRejected means Keycloak refused the loopback redirect URI.
+var errRedirectRejected = errors.New("redirect URI rejected by Keycloak")
+
+// bridgePage forwards the URL fragment to the server. With
+// response_mode=fragment the code never reaches us as a query param.
+const bridgePage = `<!doctype html>
+<html>
+<head><meta charset="utf-8"><title>bahn-cli login</title></head>
+<body>
+<p id="msg">Completing login...</p>
+<script>
+  const params = new URLSearchParams(location.hash.slice(1));
+  fetch('/exchange?' + params.toString())
+    .then(r => r.text())
+    .then(t => { document.getElementById('msg').textContent = t; window.close(); })
+    .catch(() => { document.getElementById('msg').textContent = 'Login failed, check the terminal.'; });
+</script>
+</body>
+</html>
+`
+
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// callbackServer receives the OIDC redirect on localhost.
+type callbackServer struct {
+	listener net.Listener
+	server   *http.Server
+	state    string
+	results  chan callbackResult
+}
+
+// startCallbackServer listens on a random localhost port and serves
+// /callback (bridge page) and /exchange (fragment params). Only
+// deliveries carrying state are accepted.
+func startCallbackServer(state string) (*callbackServer, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &callbackServer{
+		listener: ln,
+		state:    state,
+		results:  make(chan callbackResult, 1),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", s.handleCallback)
+	mux.HandleFunc("/exchange", s.handleExchange)
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() { _ = s.server.Serve(ln) }()
+	return s, nil
+}
+
+// RedirectURI returns the URI Keycloak should redirect to.
+func (s *callbackServer) RedirectURI() string {
+	port := s.listener.Addr().(*net.TCPAddr).Port
+	return fmt.Sprintf("http://127.0.0.1:%d/callback", port)
+}
+
+// Wait blocks until the browser delivers the callback or timeout elapses.
+func (s *callbackServer) Wait(timeout time.Duration) (code string, err error) {
+	select {
+	case res := <-s.results:
+		return res.code, res.err
+	case <-time.After(timeout):
+		return "", fmt.Errorf("timed out after %s waiting for browser login", timeout)
+	}
+}
+
+// Close shuts the server down.
+func (s *callbackServer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+func (s *callbackServer) handleCallback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = fmt.Fprint(w, bridgePage)
+}
+
+func (s *callbackServer) handleExchange(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	code, err := extractFragmentParams(r.URL.RawQuery, s.state)
+	// Any local page can hit /exchange; a delivery without our state
+	// must not win the race against the real browser callback.
+	if errors.Is(err, errStateMismatch) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintln(w, "Unexpected login state, ignored.")
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintln(w, "Login failed, check the terminal.")
+	} else {
+		_, _ = fmt.Fprintln(w, "Login complete. You can close this window.")
+	}
+
+	select {
+	case s.results <- callbackResult{code: code, err: err}:
+	default:
+		// A result is already pending; ignore duplicate deliveries.
+	}
+}
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/steipete/sweetcookie"
+)
+
+// CookieSource selects where Refresh reads the Keycloak session cookies.
+// A cookie jar file wins over browsers. With no browser set, all
+// supported browsers are read and merged.
+type CookieSource struct {
+	Browser     string
+	Profile     string
+	CookiesFile string
+}
+
+var (
+	cookieSourceMu sync.Mutex
+	cookieSource   CookieSource
+)
+
+func setCookieSource(src CookieSource) {
+	cookieSourceMu.Lock()
+	defer cookieSourceMu.Unlock()
+	cookieSource = src
+}
+
+// ConfiguredCookieSource returns the cookie source from [auth] config.
+func ConfiguredCookieSource() CookieSource {
+	cookieSourceMu.Lock()
+	defer cookieSourceMu.Unlock()
+	return cookieSource
+}
+
+// Validate checks the browser name and flag combination.
+func (src CookieSource) Validate() error {
+	if src.Browser != "" {
+		if _, err := parseBrowser(src.Browser); err != nil {
+			return err
+		}
+	}
+	if src.Profile != "" && src.Browser == "" {
+		return fmt.Errorf("a browser profile requires a browser")
+	}
+	return nil
+}
+
+func parseBrowser(name string) (sweetcookie.Browser, error) {
+	b := sweetcookie.Browser(strings.ToLower(name))
+	if slices.Contains(sweetcookie.DefaultBrowsers(), b) {
+		return b, nil
+	}
+	names := make([]string, 0, len(sweetcookie.DefaultBrowsers()))
+	for _, d := range sweetcookie.DefaultBrowsers() {
+		names = append(names, string(d))
+	}
+	return "", fmt.Errorf("unknown browser %q (want one of %s)", name, strings.Join(names, ", "))
+}
+
+// readSessionCookies loads the identity provider's cookies from src.
+func readSessionCookies(p *Provider, src CookieSource, onStatus func(string)) ([]*http.Cookie, error) {
+	if src.CookiesFile != "" {
+		if onStatus != nil {
+			onStatus(fmt.Sprintf("Reading cookies for %s from %s...", p.CookieURL(), src.CookiesFile))
+		}
+		cookies, err := readCookieJar(src.CookiesFile, p.AuthURL, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if len(cookies) == 0 {
+			return nil, fmt.Errorf("no cookies for %s in %s — export a fresh cookie jar after logging into bahn.de", p.CookieURL(), src.CookiesFile)
+		}
+		return cookies, nil
+	}
+
+	opts := sweetcookie.Options{
+		URL:  p.CookieURL(),
+		Mode: sweetcookie.ModeMerge,
+	}
+	where := "browser"
+	if src.Browser != "" {
+		b, err := parseBrowser(src.Browser)
+		if err != nil {
+			return nil, err
+		}
+		opts.Browsers = []sweetcookie.Browser{b}
+		where = string(b)
+		if src.Profile != "" {
+			opts.Profiles = map[sweetcookie.Browser]string{b: src.Profile}
+			where += fmt.Sprintf(" (profile %q)", src.Profile)
+		}
+	}
+
+	if onStatus != nil {
+		onStatus(fmt.Sprintf("Reading %s cookies for %s...", where, p.CookieURL()))
+	}
+	result, err := sweetcookie.Get(context.Background(), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s cookies: %w (make sure you've logged into bahn.de recently)", where, err)
+	}
+	if len(result.Cookies) == 0 {
+		return nil, fmt.Errorf("no cookies found for %s in %s — log into bahn.de there first, then retry", p.CookieURL(), where)
+	}
+
+	cookies := make([]*http.Cookie, 0, len(result.Cookies))
+	for _, c := range result.Cookies {
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return cookies, nil
+}
+
+// readCookieJar parses a Netscape/curl cookie jar and returns the
+// unexpired cookies a browser would send to rawURL. Pass the auth
+// endpoint: Keycloak scopes its session cookies to the realm path.
+func readCookieJar(path, rawURL string, now time.Time) ([]*http.Cookie, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cookie jar: %w", err)
+	}
+	defer f.Close()
+
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// curl marks HttpOnly cookies with a prefix on an otherwise
+		// commented-out line.
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("cookie jar %s line %d: expected 7 tab-separated fields, got %d", path, lineNo, len(fields))
+		}
+		domain, cookiePath, secure, expires, name, value := fields[0], fields[2], fields[3], fields[4], fields[5], fields[6]
+
+		if !domainMatches(u.Hostname(), domain) || !pathMatches(u.Path, cookiePath) {
+			continue
+		}
+		if strings.EqualFold(secure, "TRUE") && u.Scheme != "https" {
+			continue
+		}
+		if exp, err := strconv.ParseInt(expires, 10, 64); err == nil && exp != 0 && time.Unix(exp, 0).Before(now) {
+			continue
+		}
+		cookies = append(cookies, &http.Cookie{Name: name, Value: value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading cookie jar: %w", err)
+	}
+	return cookies, nil
+}
+
+func domainMatches(host, domain string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	host = strings.ToLower(host)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// pathMatches implements the RFC 6265 path-match: cookiePath is reqPath
+// or a prefix of it ending at a path segment boundary.
+func pathMatches(reqPath, cookiePath string) bool {
+	if reqPath == "" {
+		reqPath = "/"
+	}
+	if !strings.HasPrefix(reqPath, cookiePath) {
+		return false
+	}
+	return len(reqPath) == len(cookiePath) || strings.HasSuffix(cookiePath, "/") || reqPath[len(cookiePath)] == '/'
+}
+
+// SessionCookie is a Keycloak session cookie kept with the tokens.
+type SessionCookie struct {
+	Name    string    `json:"name"`
+	Value   string    `json:"value"`
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+// sessionCookies returns the unexpired stored cookies.
+func (t *TokenSet) sessionCookies(now time.Time) []*http.Cookie {
+	cookies := make([]*http.Cookie, 0, len(t.SessionCookies))
+	for _, c := range t.SessionCookies {
+		if !c.Expires.IsZero() && c.Expires.Before(now) {
+			continue
+		}
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return cookies
+}
+
+// mergeSessionCookies applies Keycloak's Set-Cookie updates to the
+// cookies we sent. Deleted or expired cookies are dropped.
+func mergeSessionCookies(sent, set []*http.Cookie, now time.Time) []SessionCookie {
+	jar := make(map[string]SessionCookie, len(sent))
+	for _, c := range sent {
+		jar[c.Name] = SessionCookie{Name: c.Name, Value: c.Value}
+	}
+	for _, c := range set {
+		expires := c.Expires
+		if c.MaxAge > 0 {
+			expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		if c.MaxAge < 0 || c.Value == "" || (!expires.IsZero() && expires.Before(now)) {
+			delete(jar, c.Name)
+			continue
+		}
+		jar[c.Name] = SessionCookie{Name: c.Name, Value: c.Value, Expires: expires}
+	}
+
+	out := make([]SessionCookie, 0, len(jar))
+	for _, c := range jar {
+		out = append(out, c)
+	}
+	slices.SortFunc(out, func(a, b SessionCookie) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
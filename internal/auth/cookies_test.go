@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCookieJarRealmPath(t *testing.T) {
+	future := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	past := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	jar := strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		"#HttpOnly_accounts.bahn.de\tFALSE\t/auth/realms/db/\tTRUE\t" + future + "\tKEYCLOAK_IDENTITY\tidentity",
+		"accounts.bahn.de\tFALSE\t/auth/realms/db/\tTRUE\t0\tAUTH_SESSION_ID\tsession",
+		".bahn.de\tTRUE\t/\tFALSE\t" + future + "\tconsent\tyes",
+		"accounts.bahn.de\tFALSE\t/auth/realms/dbx/\tTRUE\t" + future + "\tOTHER_REALM\tx",
+		"accounts.bahn.de\tFALSE\t/auth/realms/db/\tTRUE\t" + past + "\tKEYCLOAK_SESSION\told",
+		"www.bahn.de\tFALSE\t/\tTRUE\t" + future + "\tshop\tx",
+	}, "\n")
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	if err := os.WriteFile(path, []byte(jar), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cookies, err := readCookieJar(path, DefaultProvider().AuthURL, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range cookies {
+		names = append(names, c.Name)
+	}
+	want := []string{"KEYCLOAK_IDENTITY", "AUTH_SESSION_ID", "consent"}
+	if !slices.Equal(names, want) {
+		t.Errorf("cookies = %v, want %v", names, want)
+	}
+}
+
+func TestPathMatches(t *testing.T) {
+	tests := []struct {
+		req, cookie string
+		want        bool
+	}{
+		{"/auth/realms/db/protocol/openid-connect/auth", "/", true},
+		{"/auth/realms/db/protocol/openid-connect/auth", "/auth/realms/db/", true},
+		{"/auth/realms/db/protocol/openid-connect/auth", "/auth/realms/db", true},
+		{"/auth/realms/dbx/protocol", "/auth/realms/db", false},
+		{"/", "/auth/realms/db/", false},
+		{"", "/", true},
+	}
+	for _, tt := range tests {
+		if got := pathMatches(tt.req, tt.cookie); got != tt.want {
+			t.Errorf("pathMatches(%q, %q) = %v, want %v", tt.req, tt.cookie, got, tt.want)
+		}
+	}
+}
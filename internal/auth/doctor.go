@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/havocked/bahn-cli/internal/app"
+	"github.com/steipete/sweetcookie"
+)
+
+// Recovery actions, taken from the error contract so they match what the
+// same failure reports elsewhere.
+var (
+	actionLogin   = app.Action(app.CodeSessionExpired)
+	actionRefresh = app.Action(app.CodeTokenExpired)
+)
+
+// actionNetwork names the identity provider, which app's generic network
+// action cannot.
+const actionNetwork = "check network connectivity to the identity provider"
+
+// Check is one diagnostic result. Action says how to recover when OK is
+// false.
+type Check struct {
+	Check  string `json:"check" human:"Check"`
+	OK     bool   `json:"ok" human:"OK"`
+	Detail string `json:"detail,omitempty" human:"Detail,omitempty"`
+	Action string `json:"action,omitempty" human:"Action,omitempty"`
+}
+
+// Diagnose runs the auth checks for the active account in order: token
+// store, expiry, stored and browser cookies, Keycloak reachability and a
+// prompt=none probe. Each result is passed to onCheck as soon as it is
+// known and also returned. It never refreshes or saves tokens.
+func Diagnose(onCheck func(Check)) []Check {
+	var checks []Check
+	add := func(c Check) {
+		checks = append(checks, c)
+		if onCheck != nil {
+			onCheck(c)
+		}
+	}
+
+	tokens := diagnoseTokens(add)
+
+	p, err := currentProvider()
+	if err != nil {
+		add(Check{Check: "provider", Detail: err.Error(), Action: "fix [auth] issuer in the config file"})
+		return checks
+	}
+
+	cookies := diagnoseCookies(p, tokens, add)
+
+	if err := pingIssuer(p); err != nil {
+		add(Check{Check: "keycloak", Detail: err.Error(), Action: actionNetwork})
+		return checks
+	}
+	add(Check{Check: "keycloak", OK: true, Detail: p.Issuer})
+
+	if len(cookies) == 0 {
+		add(Check{Check: "prompt_none", Detail: "no session cookies to probe with", Action: actionLogin})
+		return checks
+	}
+	switch _, err := authorizeSilently(p, cookies); {
+	case err == nil:
+		add(Check{Check: "prompt_none", OK: true, Detail: "Keycloak session is alive"})
+	case errors.Is(err, ErrSessionExpired):
+		add(Check{Check: "prompt_none", Detail: "Keycloak answered login_required", Action: actionLogin})
+	default:
+		add(Check{Check: "prompt_none", Detail: err.Error(), Action: actionRefresh})
+	}
+	return checks
+}
+
+// diagnoseTokens checks the token store and expiry and returns the
+// stored tokens, if any.
+func diagnoseTokens(add func(Check)) *TokenSet {
+	account := ActiveAccount()
+	tokens, err := LoadTokens()
+	switch {
+	case err != nil:
+		add(Check{Check: "token_store", Detail: fmt.Sprintf("account %s: %v", account, err), Action: actionLogin})
+		return nil
+	case tokens == nil:
+		add(Check{Check: "token_store", Detail: fmt.Sprintf("account %s: no tokens stored", account), Action: actionLogin})
+		return nil
+	}
+	if _, err := ParseJWT(tokens.AccessToken); err != nil {
+		add(Check{Check: "token_store", Detail: fmt.Sprintf("account %s: stored access token unreadable: %v", account, err), Action: actionLogin})
+		return nil
+	}
+	add(Check{Check: "token_store", OK: true, Detail: fmt.Sprintf("account %s in %s", account, StorageFormat(account))})
+
+	if tokens.IsExpired() {
+		add(Check{Check: "token_expiry", Detail: "expired at " + tokens.ExpiresAt.Format(time.RFC3339), Action: actionRefresh})
+	} else {
+		add(Check{Check: "token_expiry", OK: true, Detail: fmt.Sprintf("valid for %s", tokens.TimeRemaining().Round(time.Second))})
+	}
+	return tokens
+}
+
+// diagnoseCookies reports the stored session cookies, the cookie jar
+// file and every supported browser, and returns the cookies the probe
+// should use: the stored ones, else the configured source's.
+func diagnoseCookies(p *Provider, tokens *TokenSet, add func(Check)) []*http.Cookie {
+	var probe []*http.Cookie
+	if tokens != nil {
+		if stored := tokens.sessionCookies(time.Now()); len(stored) > 0 {
+			add(Check{Check: "cookies:stored", OK: true, Detail: fmt.Sprintf("%d session cookies", len(stored))})
+			probe = stored
+		} else {
+			add(Check{Check: "cookies:stored", Detail: "no unexpired session cookies stored with the tokens", Action: actionLogin})
+		}
+	}
+
+	src := ConfiguredCookieSource()
+	if src.CookiesFile != "" {
+		cookies, err := readCookieJar(src.CookiesFile, p.AuthURL, time.Now())
+		switch {
+		case err != nil:
+			add(Check{Check: "cookies:file", Detail: err.Error(), Action: "export a fresh cookie jar after logging into bahn.de"})
+		case len(cookies) == 0:
+			add(Check{Check: "cookies:file", Detail: "no cookies for " + p.CookieURL(), Action: "export a fresh cookie jar after logging into bahn.de"})
+		default:
+			add(Check{Check: "cookies:file", OK: true, Detail: fmt.Sprintf("%d cookies in %s", len(cookies), src.CookiesFile)})
+			if probe == nil {
+				probe = cookies
+			}
+		}
+	}
+
+	for _, b := range sweetcookie.DefaultBrowsers() {
+		opts := sweetcookie.Options{URL: p.CookieURL(), Browsers: []sweetcookie.Browser{b}}
+		if src.Browser != "" && strings.EqualFold(src.Browser, string(b)) && src.Profile != "" {
+			opts.Profiles = map[sweetcookie.Browser]string{b: src.Profile}
+		}
+		name := "cookies:" + string(b)
+		result, err := sweetcookie.Get(context.Background(), opts)
+		switch {
+		case err != nil:
+			add(Check{Check: name, Detail: err.Error(), Action: fmt.Sprintf("log into bahn.de in %s", b)})
+		case len(result.Cookies) == 0:
+			add(Check{Check: name, Detail: "no cookies for " + p.CookieURL(), Action: fmt.Sprintf("log into bahn.de in %s", b)})
+		default:
+			add(Check{Check: name, OK: true, Detail: fmt.Sprintf("%d cookies", len(result.Cookies))})
+			// Only the configured browser (or any, if none is set) feeds the probe.
+			if probe == nil && src.CookiesFile == "" && (src.Browser == "" || strings.EqualFold(src.Browser, string(b))) {
+				probe = make([]*http.Cookie, 0, len(result.Cookies))
+				for _, c := range result.Cookies {
+					probe = append(probe, &http.Cookie{Name: c.Name, Value: c.Value})
+				}
+			}
+		}
+	}
+	return probe
+}
+
+// pingIssuer fetches the discovery document to see whether Keycloak is
+// reachable.
+func pingIssuer(p *Provider) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("discovery document returned status %d", resp.StatusCode)
+	}
+	return nil
+}
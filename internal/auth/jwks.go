@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/havocked/bahn-cli/internal/config"
+)
+
+const (
+	defaultClockSkew = 60 * time.Second
+	jwksCacheTTL     = 24 * time.Hour
+	// jwksRefetchInterval limits refetches for unknown key IDs.
+	jwksRefetchInterval = time.Minute
+)
+
+// InvalidTokenError is returned when a JWT fails verification.
+// Reason is a short machine-readable cause such as "bad_signature".
+type InvalidTokenError struct {
+	Reason string
+	Err    error
+}
+
+func (e *InvalidTokenError) Error() string {
+	if e.Err == nil {
+		return "invalid_token: " + e.Reason
+	}
+	return fmt.Sprintf("invalid_token: %s: %v", e.Reason, e.Err)
+}
+
+func (e *InvalidTokenError) Unwrap() error {
+	return e.Err
+}
+
+func invalidToken(reason string, format string, args ...any) error {
+	return &InvalidTokenError{Reason: reason, Err: fmt.Errorf(format, args...)}
+}
+
+// audience accepts the "aud" claim as a string or a list of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// Verifier checks RS256 signatures against the realm's JWKS and validates
+// iss, aud, exp and iat.
+type Verifier struct {
+	JWKSURL   string
+	Issuer    string
+	Audience  string
+	ClockSkew time.Duration
+	Client    *http.Client
+	// CachePath is an on-disk JWKS cache; empty disables it.
+	CachePath string
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	// lastFetch is the last fetch attempt, successful or not.
+	lastFetch time.Time
+}
+
+// NewVerifier returns a Verifier for the provider's JWKS, issuer and
+// client ID.
+func NewVerifier(p *Provider) *Verifier {
+	cachePath := ""
+	if dir, err := config.ConfigDir(); err == nil {
+		cachePath = filepath.Join(dir, "jwks.json")
+	}
+	return &Verifier{
+		JWKSURL:   p.JWKSURL,
+		Issuer:    p.Issuer,
+		Audience:  p.ClientID,
+		ClockSkew: defaultClockSkew,
+		Client:    &http.Client{Timeout: 10 * time.Second},
+		CachePath: cachePath,
+	}
+}
+
+var (
+	verifierMu     sync.Mutex
+	activeVerifier *Verifier
+)
+
+func resetVerifier() {
+	verifierMu.Lock()
+	defer verifierMu.Unlock()
+	activeVerifier = nil
+}
+
+// VerifyJWT verifies token with the configured JWKS and returns its claims.
+func VerifyJWT(token string) (*Claims, error) {
+	verifierMu.Lock()
+	v := activeVerifier
+	verifierMu.Unlock()
+	if v == nil {
+		p, err := currentProvider()
+		if err != nil {
+			return nil, err
+		}
+		v = NewVerifier(p)
+		verifierMu.Lock()
+		activeVerifier = v
+		verifierMu.Unlock()
+	}
+	return v.Verify(token)
+}
+
+// Verify checks the signature and claims of token.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalidToken("malformed", "expected 3 parts")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, invalidToken("malformed", "header: %v", err)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, invalidToken("malformed", "header: %v", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, invalidToken("unsupported_alg", "alg %q", header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalidToken("malformed", "signature: %v", err)
+	}
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, invalidToken("bad_signature", "%v", err)
+	}
+
+	claims, err := ParseJWT(token)
+	if err != nil {
+		return nil, &InvalidTokenError{Reason: "malformed", Err: err}
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) checkClaims(c *Claims, now time.Time) error {
+	if c.Iss != v.Issuer {
+		return invalidToken("bad_issuer", "got %q, want %q", c.Iss, v.Issuer)
+	}
+	if !slices.Contains(c.Aud, v.Audience) {
+		return invalidToken("bad_audience", "%q not in %v", v.Audience, []string(c.Aud))
+	}
+	if c.Exp == 0 {
+		return invalidToken("missing_exp", "no exp claim")
+	}
+	if exp := time.Unix(c.Exp, 0); now.After(exp.Add(v.ClockSkew)) {
+		return invalidToken("expired", "expired at %s", exp.UTC().Format(time.RFC3339))
+	}
+	if c.Iat != 0 {
+		if iat := time.Unix(c.Iat, 0); iat.After(now.Add(v.ClockSkew)) {
+			return invalidToken("issued_in_future", "iat %s", iat.UTC().Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// key returns the public key for kid, refetching the JWKS when the key is
+// unknown or the cache is stale. An unknown kid may mean the realm rotated
+// its keys, but it is also what every forged token carries, so a fresh
+// cache is refetched at most once per jwksRefetchInterval.
+func (v *Verifier) key(kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.keys == nil {
+		v.loadCache()
+	}
+	fresh := time.Since(v.fetchedAt) < jwksCacheTTL
+	if k, ok := v.keys[kid]; ok && fresh {
+		return k, nil
+	}
+	if fresh && time.Since(v.lastFetch) < jwksRefetchInterval {
+		return nil, invalidToken("unknown_key", "kid %q not in JWKS", kid)
+	}
+	v.lastFetch = time.Now()
+	if err := v.fetch(); err != nil {
+		return nil, err
+	}
+	if k, ok := v.keys[kid]; ok {
+		return k, nil
+	}
+	return nil, invalidToken("unknown_key", "kid %q not in JWKS", kid)
+}
+
+// jwksCache is the on-disk layout of the JWKS cache.
+type jwksCache struct {
+	URL       string          `json:"url"`
+	FetchedAt time.Time       `json:"fetchedAt"`
+	JWKS      json.RawMessage `json:"jwks"`
+}
+
+func (v *Verifier) loadCache() {
+	v.keys = map[string]*rsa.PublicKey{}
+	if v.CachePath == "" {
+		return
+	}
+	data, err := os.ReadFile(v.CachePath)
+	if err != nil {
+		return
+	}
+	var c jwksCache
+	if json.Unmarshal(data, &c) != nil || c.URL != v.JWKSURL {
+		return
+	}
+	if keys, err := parseJWKS(c.JWKS); err == nil {
+		v.keys = keys
+		v.fetchedAt = c.FetchedAt
+		v.lastFetch = c.FetchedAt
+	}
+}
+
+func (v *Verifier) fetch() error {
+	resp, err := v.Client.Get(v.JWKSURL)
+	if err != nil {
+		return fmt.Errorf("fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching JWKS: status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("fetching JWKS: %w", err)
+	}
+	keys, err := parseJWKS(body)
+	if err != nil {
+		return err
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+
+	if v.CachePath != "" {
+		data, err := json.MarshalIndent(jwksCache{URL: v.JWKSURL, FetchedAt: v.fetchedAt, JWKS: body}, "", "  ")
+		if err == nil {
+			_ = writeFileAtomic(v.CachePath, data, 0o600)
+		}
+	}
+	return nil
+}
+
+func parseJWKS(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parsing JWKS: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("parsing JWKS: no usable RSA signing keys")
+	}
+	return keys, nil
+}
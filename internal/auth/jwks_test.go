@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testIssuer = "https://kc.test/realms/db"
+
+// fakeJWKS serves the public half of an RSA key as a one-key JWKS.
+type fakeJWKS struct {
+	*httptest.Server
+	key  *rsa.PrivateKey
+	kid  string
+	hits atomic.Int32
+}
+
+func newFakeJWKS(t *testing.T, kid string) *fakeJWKS {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := &fakeJWKS{key: key, kid: kid}
+	j.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		j.hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": kid,
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(j.Close)
+	return j
+}
+
+func (j *fakeJWKS) verifier(cachePath string) *Verifier {
+	return &Verifier{
+		JWKSURL:   j.URL,
+		Issuer:    testIssuer,
+		Audience:  clientID,
+		ClockSkew: defaultClockSkew,
+		Client:    j.Client(),
+		CachePath: cachePath,
+	}
+}
+
+// signJWT returns an RS256 JWT over claims with the given header fields.
+func signJWT(t *testing.T, key *rsa.PrivateKey, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	enc := func(v any) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := enc(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + enc(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// validClaims returns claims that pass every check; tests override one.
+func validClaims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss": testIssuer,
+		"aud": []string{"account", clientID},
+		"sub": "user-1",
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+	}
+}
+
+func TestVerifierVerify(t *testing.T) {
+	j := newFakeJWKS(t, "k1")
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	with := func(key string, value any) map[string]any {
+		c := validClaims()
+		c[key] = value
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		reason string // empty means valid
+	}{
+		{"valid", signJWT(t, j.key, "RS256", "k1", validClaims()), ""},
+		{"aud as string", signJWT(t, j.key, "RS256", "k1", with("aud", clientID)), ""},
+		{"exp within skew", signJWT(t, j.key, "RS256", "k1", with("exp", now.Add(-30*time.Second).Unix())), ""},
+		{"bad signature", signJWT(t, other, "RS256", "k1", validClaims()), "bad_signature"},
+		{"wrong issuer", signJWT(t, j.key, "RS256", "k1", with("iss", "https://evil.test/realms/db")), "bad_issuer"},
+		{"audience without client", signJWT(t, j.key, "RS256", "k1", with("aud", []string{"account"})), "bad_audience"},
+		{"expired past skew", signJWT(t, j.key, "RS256", "k1", with("exp", now.Add(-2*time.Minute).Unix())), "expired"},
+		{"missing exp", signJWT(t, j.key, "RS256", "k1", with("exp", 0)), "missing_exp"},
+		{"issued in the future", signJWT(t, j.key, "RS256", "k1", with("iat", now.Add(2*time.Minute).Unix())), "issued_in_future"},
+		{"alg HS256", signJWT(t, j.key, "HS256", "k1", validClaims()), "unsupported_alg"},
+		{"alg none", signJWT(t, j.key, "none", "k1", validClaims()), "unsupported_alg"},
+		{"malformed", "not.a-jwt", "malformed"},
+	}
+	v := j.verifier("")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(tt.token)
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if claims.Sub != "user-1" {
+					t.Errorf("sub = %q", claims.Sub)
+				}
+				return
+			}
+			var tokErr *InvalidTokenError
+			if !errors.As(err, &tokErr) || tokErr.Reason != tt.reason {
+				t.Errorf("err = %v, want reason %s", err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestVerifierUnknownKidRateLimited(t *testing.T) {
+	j := newFakeJWKS(t, "k1")
+	v := j.verifier("")
+	forged := signJWT(t, j.key, "RS256", "rotated", validClaims())
+
+	for range 3 {
+		_, err := v.Verify(forged)
+		var tokErr *InvalidTokenError
+		if !errors.As(err, &tokErr) || tokErr.Reason != "unknown_key" {
+			t.Fatalf("err = %v, want unknown_key", err)
+		}
+	}
+	if got := j.hits.Load(); got != 1 {
+		t.Errorf("JWKS fetches = %d, want 1", got)
+	}
+
+	// After the interval an unknown kid may be a rotation again.
+	v.lastFetch = time.Now().Add(-2 * jwksRefetchInterval)
+	_, _ = v.Verify(forged)
+	if got := j.hits.Load(); got != 2 {
+		t.Errorf("JWKS fetches after the interval = %d, want 2", got)
+	}
+	// Known keys never wait for the interval.
+	if _, err := v.Verify(signJWT(t, j.key, "RS256", "k1", validClaims())); err != nil {
+		t.Errorf("valid token: %v", err)
+	}
+}
+
+func TestVerifierDiskCache(t *testing.T) {
+	j := newFakeJWKS(t, "k1")
+	cache := filepath.Join(t.TempDir(), "jwks.json")
+	token := signJWT(t, j.key, "RS256", "k1", validClaims())
+
+	if _, err := j.verifier(cache).Verify(token); err != nil {
+		t.Fatalf("first verify: %v", err)
+	}
+	// A new process reads the cache instead of fetching.
+	if _, err := j.verifier(cache).Verify(token); err != nil {
+		t.Fatalf("cached verify: %v", err)
+	}
+	if got := j.hits.Load(); got != 1 {
+		t.Errorf("JWKS fetches = %d, want 1", got)
+	}
+
+	// A cache written for another JWKS URL is ignored.
+	other := newFakeJWKS(t, "k1")
+	v := other.verifier(cache)
+	if _, err := v.Verify(signJWT(t, other.key, "RS256", "k1", validClaims())); err != nil {
+		t.Fatalf("verify against other JWKS: %v", err)
+	}
+	if got := other.hits.Load(); got != 1 {
+		t.Errorf("other JWKS fetches = %d, want 1", got)
+	}
+	// ...and so is one that has outlived its TTL.
+	v = other.verifier(cache)
+	v.loadCache()
+	v.fetchedAt = time.Now().Add(-jwksCacheTTL - time.Minute)
+	if _, err := v.Verify(signJWT(t, other.key, "RS256", "k1", validClaims())); err != nil {
+		t.Fatalf("verify with stale cache: %v", err)
+	}
+	if got := other.hits.Load(); got != 2 {
+		t.Errorf("other JWKS fetches after TTL = %d, want 2", got)
+	}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/havocked/bahn-cli/internal/app"
+)
+
+// KeepAliveOptions tunes the keep-alive loop. Zero values use defaults.
+type KeepAliveOptions struct {
+	// Lead is how long before ExpiresAt a refresh is attempted.
+	Lead time.Duration
+	// MinBackoff and MaxBackoff bound the retry delay after a failure.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Source is where session cookies are read when the stored ones fail.
+	Source CookieSource
+	// OnCycle is called after every refresh attempt.
+	OnCycle func(KeepAliveCycle)
+}
+
+// KeepAliveCycle reports one refresh attempt.
+type KeepAliveCycle struct {
+	Attempt  int
+	Tokens   *TokenSet // nil on failure
+	Err      error
+	Failures int // consecutive failures, 0 after a success
+	Next     time.Duration
+}
+
+// KeepAlive refreshes the active account's tokens shortly before they
+// expire until ctx is cancelled, which returns nil. Failures are retried
+// with jittered exponential backoff; once Keycloak reports the session
+// as expired, or there are no session cookies left to try, it returns a
+// session_expired error wrapping ErrSessionExpired or ErrNoSessionCookies.
+func KeepAlive(ctx context.Context, opts KeepAliveOptions) error {
+	if opts.Lead <= 0 {
+		opts.Lead = 60 * time.Second
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 5 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 2 * time.Minute
+	}
+
+	tokens, err := LoadTokens()
+	if err != nil {
+		return err
+	}
+	if tokens == nil {
+		return app.NewError(app.CodeAuthRequired, errNotAuthenticated)
+	}
+
+	failures := 0
+	wait := untilRefresh(tokens, opts.Lead)
+	for attempt := 1; ; attempt++ {
+		if err := checkLead(tokens, opts.Lead); err != nil {
+			return err
+		}
+		if err := sleepCtx(ctx, wait); err != nil {
+			return nil
+		}
+
+		fresh, err := RefreshTokens(tokens, opts.Source, nil)
+		cycle := KeepAliveCycle{Attempt: attempt, Err: err}
+		if err == nil {
+			tokens = fresh
+			failures = 0
+			wait = untilRefresh(tokens, opts.Lead)
+			cycle.Tokens = fresh
+		} else {
+			failures++
+			wait = backoff(failures, opts.MinBackoff, opts.MaxBackoff)
+		}
+		cycle.Failures = failures
+		cycle.Next = wait
+
+		dead := errors.Is(err, ErrSessionExpired) || errors.Is(err, ErrNoSessionCookies)
+		if dead {
+			cycle.Next = 0
+		}
+		if opts.OnCycle != nil {
+			opts.OnCycle(cycle)
+		}
+		if dead {
+			return err
+		}
+	}
+}
+
+// checkLead rejects a lead that is not below the token lifetime: every
+// fresh token would be due at once and the loop would spin.
+func checkLead(tokens *TokenSet, lead time.Duration) error {
+	claims, err := ParseJWT(tokens.AccessToken)
+	if err != nil || claims.Iat == 0 || claims.Exp <= claims.Iat {
+		return nil // lifetime unknown
+	}
+	lifetime := time.Duration(claims.Exp-claims.Iat) * time.Second
+	if lead >= lifetime {
+		return app.Errorf(app.CodeInvalidInput, "lead %s is not below the token lifetime %s", lead, lifetime)
+	}
+	return nil
+}
+
+// untilRefresh is the delay until tokens are lead away from expiry.
+func untilRefresh(tokens *TokenSet, lead time.Duration) time.Duration {
+	return max(time.Until(tokens.ExpiresAt.Add(-lead)), 0)
+}
+
+// backoff doubles from lo per failure, capped at hi, with ±20% jitter.
+func backoff(failures int, lo, hi time.Duration) time.Duration {
+	d := lo
+	for i := 1; i < failures && d < hi; i++ {
+		d *= 2
+	}
+	d = min(d, hi)
+	jitter := time.Duration(rand.Int64N(int64(d)/5*2+1)) - d/5
+	return d + jitter
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/havocked/bahn-cli/internal/app"
+	"github.com/havocked/bahn-cli/internal/auth/authtest"
+)
+
+func TestKeepAliveRejectsLeadAboveLifetime(t *testing.T) {
+	useTempStore(t)
+	now := time.Now()
+	tokens := &TokenSet{
+		AccessToken: authtest.JWT(map[string]any{"iat": now.Unix(), "exp": now.Add(5 * time.Minute).Unix()}),
+		ExpiresAt:   now.Add(5 * time.Minute),
+	}
+	if err := SaveTokens(tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, lead := range []time.Duration{5 * time.Minute, 10 * time.Minute} {
+		err := KeepAlive(ctx, KeepAliveOptions{
+			Lead:    lead,
+			OnCycle: func(KeepAliveCycle) { t.Error("refresh attempted") },
+		})
+		var e *app.Error
+		if !errors.As(err, &e) || e.Code != app.CodeInvalidInput {
+			t.Errorf("lead %s: err = %v, want invalid_input", lead, err)
+		}
+	}
+}
+
+func TestBackoffGrowsAndCaps(t *testing.T) {
+	lo, hi := time.Second, 10*time.Second
+	want := []time.Duration{lo, 2 * lo, 4 * lo, 8 * lo, hi, hi, hi}
+	for i, base := range want {
+		failures := i + 1
+		for range 50 {
+			got := backoff(failures, lo, hi)
+			if got < base-base/5 || got > base+base/5 {
+				t.Fatalf("backoff(%d) = %s, want %s ±20%%", failures, got, base)
+			}
+		}
+	}
+}
+
+// saveDueTokens stores tokens that are due for a refresh right away.
+func saveDueTokens(t *testing.T, cookies []SessionCookie) {
+	t.Helper()
+	tokens := &TokenSet{
+		AccessToken:    authtest.JWT(map[string]any{"preferred_username": "erika"}),
+		ExpiresAt:      time.Now().Add(10 * time.Second),
+		SessionCookies: cookies,
+	}
+	if err := SaveTokens(tokens); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeepAliveStopsWhenSessionIsGone(t *testing.T) {
+	tests := []struct {
+		name    string
+		cookies []SessionCookie
+		want    error
+	}{
+		{"login_required", []SessionCookie{{Name: "KEYCLOAK_IDENTITY", Value: "stale"}}, ErrSessionExpired},
+		{"no cookies anywhere", nil, ErrNoSessionCookies},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempStore(t)
+			kc := authtest.NewKeycloak(t)
+			kc.SessionCookie = "identity"
+			useProvider(t, keycloakProvider(kc))
+			useEmptyCookieJar(t)
+			saveDueTokens(t, tt.cookies)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			var cycles []KeepAliveCycle
+			err := KeepAlive(ctx, KeepAliveOptions{
+				Source:  ConfiguredCookieSource(),
+				OnCycle: func(c KeepAliveCycle) { cycles = append(cycles, c) },
+			})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if code, _, _ := app.Describe(err); code != app.CodeSessionExpired || app.ExitCode(err) != 2 {
+				t.Errorf("code = %s exit = %d, want session_expired and 2", code, app.ExitCode(err))
+			}
+			if len(cycles) != 1 || cycles[0].Next != 0 {
+				t.Errorf("cycles = %+v, want one final cycle", cycles)
+			}
+		})
+	}
+}
+
+func TestKeepAliveBacksOffOnNetworkErrors(t *testing.T) {
+	useTempStore(t)
+	kc := authtest.NewKeycloak(t)
+	useProvider(t, keycloakProvider(kc))
+	useEmptyCookieJar(t)
+	saveDueTokens(t, []SessionCookie{{Name: "KEYCLOAK_IDENTITY", Value: "identity"}})
+	kc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var cycles []KeepAliveCycle
+	err := KeepAlive(ctx, KeepAliveOptions{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 4 * time.Millisecond,
+		Source:     ConfiguredCookieSource(),
+		OnCycle: func(c KeepAliveCycle) {
+			cycles = append(cycles, c)
+			if len(cycles) == 5 {
+				cancel()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("KeepAlive = %v, want nil after cancel", err)
+	}
+	if len(cycles) != 5 {
+		t.Fatalf("cycles = %d, want 5", len(cycles))
+	}
+	for i, c := range cycles {
+		if c.Err == nil || c.Failures != i+1 {
+			t.Errorf("cycle %d = %+v, want failure %d", i+1, c, i+1)
+		}
+		if c.Next <= 0 || c.Next > 4*time.Millisecond+4*time.Millisecond/5 {
+			t.Errorf("cycle %d next = %s, want within the 4ms cap", i+1, c.Next)
+		}
+	}
+}
+
+func TestKeepAliveReturnsOnCancel(t *testing.T) {
+	useTempStore(t)
+	tokens := &TokenSet{AccessToken: authtest.JWT(map[string]any{}), ExpiresAt: time.Now().Add(time.Hour)}
+	if err := SaveTokens(tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- KeepAlive(ctx, KeepAliveOptions{
+			OnCycle: func(KeepAliveCycle) { t.Error("refresh attempted") },
+		})
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("KeepAlive = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("KeepAlive did not return after cancel")
+	}
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/havocked/bahn-cli/internal/auth/authtest"
+)
+
+// keycloakProvider returns a Provider for kc with the real client's
+// settings.
+func keycloakProvider(kc *authtest.Keycloak) *Provider {
+	return &Provider{
+		Issuer:      kc.URL,
+		AuthURL:     kc.URL + "/auth",
+		TokenURL:    kc.URL + "/token",
+		ClientID:    clientID,
+		Scopes:      scopes,
+		RedirectURI: realRedirectURI,
+	}
+}
+
+// authParams returns the query of an auth URL opened by the flow.
+func authParams(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse auth URL: %v", err)
+	}
+	return u.Query()
+}
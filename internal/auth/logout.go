@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var logoutClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+	Timeout: 10 * time.Second,
+}
+
+// Revoke invalidates the access token at Keycloak's revocation endpoint.
+func Revoke(tokens *TokenSet) error {
+	if tokens == nil || tokens.AccessToken == "" {
+		return errors.New("no access token")
+	}
+	p, err := currentProvider()
+	if err != nil {
+		return err
+	}
+	if p.RevocationURL == "" {
+		return errors.New("provider has no revocation endpoint")
+	}
+	data := url.Values{
+		"client_id":       {p.ClientID},
+		"token":           {tokens.AccessToken},
+		"token_type_hint": {"access_token"},
+	}
+	resp, err := logoutClient.Post(
+		p.RevocationURL,
+		"application/x-www-form-urlencoded",
+		strings.NewReader(data.Encode()),
+	)
+	if err != nil {
+		return fmt.Errorf("revoke request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("revoke failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// EndSession ends the Keycloak SSO session identified by the ID token.
+func EndSession(tokens *TokenSet) error {
+	if tokens == nil || tokens.IDToken == "" {
+		return errors.New("no ID token")
+	}
+	p, err := currentProvider()
+	if err != nil {
+		return err
+	}
+	if p.EndSessionURL == "" {
+		return errors.New("provider has no end-session endpoint")
+	}
+	params := url.Values{
+		"client_id":     {p.ClientID},
+		"id_token_hint": {tokens.IDToken},
+	}
+	resp, err := logoutClient.Get(p.EndSessionURL + "?" + params.Encode())
+	if err != nil {
+		return fmt.Errorf("logout request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	// Keycloak answers with a page or a redirect, both mean done.
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("logout failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -2,11 +2,11 @@ package auth
 
 import (
 	"bufio"
-	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,31 +16,142 @@ import (
 	"time"
 
 	"github.com/pkg/browser"
-	"github.com/steipete/sweetcookie"
-)
-
-const (
-	keycloakBaseURL = "https://accounts.bahn.de/auth/realms/db/protocol/openid-connect"
-	clientID        = "kf_web"
-	scopes          = "openid vendo"
-	realRedirectURI = "https://www.bahn.de/.resources/bahn-common-light/webresources/assets/html/auth.v2.html"
 )
 
 // Login performs the OIDC browser login flow.
-// Opens browser, user logs in, pastes callback URL.
+// Tries a localhost callback server first; if Keycloak rejects the
+// loopback redirect URI, falls back to pasting the callback URL.
 func Login(onStatus func(string)) (*TokenSet, error) {
+	p, err := currentProvider()
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := defaultLoginFlow(p).run(onStatus)
+	if err != nil {
+		return nil, err
+	}
+	captureSessionCookies(p, tokens, onStatus)
+	return tokens, nil
+}
+
+// captureSessionCookies copies the browser's fresh Keycloak session
+// cookies onto tokens, so later refreshes work without the browser.
+// Best effort: login has succeeded either way.
+func captureSessionCookies(p *Provider, tokens *TokenSet, onStatus func(string)) {
+	cookies, err := readSessionCookies(p, ConfiguredCookieSource(), nil)
+	if err != nil {
+		if onStatus != nil {
+			onStatus(fmt.Sprintf("Could not capture session cookies (%v); refresh will read them from the browser.", err))
+		}
+		return
+	}
+	tokens.SessionCookies = mergeSessionCookies(cookies, nil, time.Now())
+}
+
+// loginFlow holds the provider and I/O hooks used by Login, so the flow
+// can be driven against a fake Keycloak.
+type loginFlow struct {
+	provider    *Provider
+	openBrowser func(string) error
+	in          io.Reader
+	prompt      io.Writer
+	client      *http.Client
+	timeout     time.Duration
+}
+
+func defaultLoginFlow(p *Provider) *loginFlow {
+	return &loginFlow{
+		provider:    p,
+		openBrowser: browser.OpenURL,
+		in:          os.Stdin,
+		prompt:      os.Stderr,
+		client: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+			Timeout: 10 * time.Second,
+		},
+		timeout: 5 * time.Minute,
+	}
+}
+
+func (f *loginFlow) run(onStatus func(string)) (*TokenSet, error) {
 	verifier, challenge, err := generatePKCE()
 	if err != nil {
 		return nil, fmt.Errorf("PKCE generation failed: %w", err)
 	}
 	state := randomString(32)
 
-	authURL := buildAuthURL(realRedirectURI, state, challenge)
+	tokens, err := f.loopback(verifier, challenge, state, onStatus)
+	if !errors.Is(err, errRedirectRejected) {
+		return tokens, err
+	}
+	if onStatus != nil {
+		onStatus("Localhost redirect not accepted by Keycloak, falling back to manual paste.")
+	}
+	return f.paste(verifier, challenge, state, onStatus)
+}
+
+// loopback runs the login with a redirect to a local callback server.
+func (f *loginFlow) loopback(verifier, challenge, state string, onStatus func(string)) (*TokenSet, error) {
+	srv, err := startCallbackServer(state)
+	if err != nil {
+		return nil, fmt.Errorf("starting callback server: %w", err)
+	}
+	defer srv.Close()
+
+	redirectURI := srv.RedirectURI()
+	authURL := buildAuthURL(f.provider, redirectURI, state, challenge)
+	if err := f.probeRedirect(authURL); err != nil {
+		return nil, err
+	}
 
 	if onStatus != nil {
 		onStatus("Opening browser for login...")
+		onStatus(fmt.Sprintf("Waiting for callback on %s", redirectURI))
+	}
+	_ = f.openBrowser(authURL)
+
+	code, err := srv.Wait(f.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if onStatus != nil {
+		onStatus("Exchanging auth code for tokens...")
+	}
+	return exchangeCode(f.provider, code, verifier, redirectURI)
+}
+
+// probeRedirect loads the auth page without following redirects.
+// Keycloak answers an unregistered redirect_uri with 400 instead of the
+// login form, which is the only signal we get before opening the browser.
+func (f *loginFlow) probeRedirect(authURL string) error {
+	resp, err := f.client.Get(authURL)
+	if err != nil {
+		return fmt.Errorf("auth endpoint unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusBadRequest:
+		return errRedirectRejected
+	case resp.StatusCode >= 400:
+		return fmt.Errorf("auth endpoint returned status %d", resp.StatusCode)
 	}
-	_ = browser.OpenURL(authURL)
+	return nil
+}
+
+// paste runs the login with bahn.de's real redirect URI and asks the
+// user to paste the resulting URL.
+func (f *loginFlow) paste(verifier, challenge, state string, onStatus func(string)) (*TokenSet, error) {
+	authURL := buildAuthURL(f.provider, f.provider.RedirectURI, state, challenge)
+
+	if onStatus != nil {
+		onStatus("Opening browser for login...")
+	}
+	_ = f.openBrowser(authURL)
 
 	if onStatus != nil {
 		onStatus("")
@@ -48,8 +159,8 @@ func Login(onStatus func(string)) (*TokenSet, error) {
 		onStatus("")
 	}
 
-	fmt.Fprint(os.Stderr, "> ")
-	scanner := bufio.NewScanner(os.Stdin)
+	fmt.Fprint(f.prompt, "> ")
+	scanner := bufio.NewScanner(f.in)
 	scanner.Buffer(make([]byte, 0, 4096), 16384) // URLs can be long
 	if !scanner.Scan() {
 		return nil, fmt.Errorf("no input received")
@@ -59,64 +170,118 @@ func Login(onStatus func(string)) (*TokenSet, error) {
 		return nil, fmt.Errorf("empty URL")
 	}
 
-	code, returnedState, err := extractFragmentParams(pastedURL)
+	code, err := extractFragmentParams(pastedURL, state)
 	if err != nil {
 		return nil, err
 	}
-	if returnedState != state {
-		return nil, fmt.Errorf("state mismatch: possible CSRF attack")
-	}
 
 	if onStatus != nil {
 		onStatus("Exchanging auth code for tokens...")
 	}
-	return exchangeCode(code, verifier, realRedirectURI)
+	return exchangeCode(f.provider, code, verifier, f.provider.RedirectURI)
 }
 
 // Refresh attempts to get new tokens by reading Keycloak session cookies
 // from the browser and replaying them with a prompt=none auth request.
 // No user interaction needed if the browser session is still alive.
+// Cookies come from the source configured in [auth].
 func Refresh(onStatus func(string)) (*TokenSet, error) {
-	verifier, challenge, err := generatePKCE()
+	return RefreshFrom(ConfiguredCookieSource(), onStatus)
+}
+
+// ErrSessionExpired means Keycloak refused a prompt=none request: the
+// session is gone and only an interactive login helps.
+var ErrSessionExpired = errors.New("session expired — run `bahn auth login` to re-authenticate")
+
+// ErrNoSessionCookies means there were no Keycloak session cookies to
+// replay: none stored with the tokens and none in the cookie source.
+// Only an interactive login helps, as with ErrSessionExpired.
+var ErrNoSessionCookies = errors.New("no Keycloak session cookies")
+
+// RefreshFrom is Refresh with an explicit cookie source. The session
+// cookies saved with the tokens are tried first; src is the fallback.
+func RefreshFrom(src CookieSource, onStatus func(string)) (*TokenSet, error) {
+	p, err := currentProvider()
 	if err != nil {
-		return nil, fmt.Errorf("PKCE generation failed: %w", err)
+		return nil, err
 	}
-	state := randomString(32)
-
-	authURL := buildAuthURL(realRedirectURI, state, challenge)
-	authURL += "&prompt=none"
 
-	if onStatus != nil {
-		onStatus("Reading browser cookies for accounts.bahn.de...")
+	var storedErr error
+	if stored, _ := LoadTokens(); stored != nil && len(stored.SessionCookies) > 0 {
+		if onStatus != nil {
+			onStatus(fmt.Sprintf("Trying %d stored session cookies...", len(stored.SessionCookies)))
+		}
+		tokens, err := silentAuth(p, stored.sessionCookies(time.Now()), onStatus)
+		if err == nil {
+			return tokens, nil
+		}
+		if onStatus != nil {
+			onStatus(fmt.Sprintf("Stored session cookies did not work (%v), falling back...", err))
+		}
+		storedErr = err
 	}
 
-	// Read Keycloak session cookies from the browser
-	cookieResult, err := sweetcookie.Get(context.Background(), sweetcookie.Options{
-		URL:  "https://accounts.bahn.de/",
-		Mode: sweetcookie.ModeMerge,
-	})
+	cookies, err := readSessionCookies(p, src, onStatus)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read browser cookies: %w (make sure you've logged into bahn.de recently)", err)
+		// Whatever went wrong with the stored session outranks a browser
+		// that simply has no cookies.
+		if storedErr != nil {
+			return nil, fmt.Errorf("%w (browser fallback: %v)", storedErr, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrNoSessionCookies, err)
 	}
+	return silentAuth(p, cookies, onStatus)
+}
 
-	if len(cookieResult.Cookies) == 0 {
-		return nil, fmt.Errorf("no cookies found for accounts.bahn.de — log into bahn.de in your browser first, then retry")
+// silentAuth replays session cookies with a prompt=none auth request and
+// exchanges the returned code. The cookies, updated with whatever
+// Keycloak sets in its response, are kept on the returned tokens.
+func silentAuth(p *Provider, cookies []*http.Cookie, onStatus func(string)) (*TokenSet, error) {
+	if onStatus != nil {
+		onStatus(fmt.Sprintf("Found %d cookies, attempting silent refresh...", len(cookies)))
+	}
+	grant, err := authorizeSilently(p, cookies)
+	if err != nil {
+		return nil, err
 	}
 
 	if onStatus != nil {
-		onStatus(fmt.Sprintf("Found %d cookies, attempting silent refresh...", len(cookieResult.Cookies)))
+		onStatus("Exchanging code for tokens...")
+	}
+	tokens, err := exchangeCode(p, grant.code, grant.verifier, p.RedirectURI)
+	if err != nil {
+		return nil, err
+	}
+	tokens.SessionCookies = mergeSessionCookies(cookies, grant.setCookies, time.Now())
+	return tokens, nil
+}
+
+// silentGrant is the outcome of a successful prompt=none request.
+type silentGrant struct {
+	code       string
+	verifier   string
+	setCookies []*http.Cookie
+}
+
+// authorizeSilently sends a prompt=none auth request with cookies and
+// returns the authorization code from the redirect.
+func authorizeSilently(p *Provider, cookies []*http.Cookie) (*silentGrant, error) {
+	verifier, challenge, err := generatePKCE()
+	if err != nil {
+		return nil, fmt.Errorf("PKCE generation failed: %w", err)
 	}
+	state := randomString(32)
+
+	authURL := buildAuthURL(p, p.RedirectURI, state, challenge)
+	authURL += "&prompt=none"
 
 	// Build HTTP request with cookies
 	req, err := http.NewRequest("GET", authURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	for _, c := range cookieResult.Cookies {
-		req.AddCookie(&http.Cookie{
-			Name:  c.Name,
-			Value: c.Value,
-		})
+	for _, c := range cookies {
+		req.AddCookie(c)
 	}
 
 	client := &http.Client{
@@ -141,26 +306,25 @@ func Refresh(onStatus func(string)) (*TokenSet, error) {
 		return nil, fmt.Errorf("refresh failed: no redirect location")
 	}
 
-	code, returnedState, err := extractFragmentParams(location)
+	code, err := extractFragmentParams(location, state)
 	if err != nil {
 		if strings.Contains(location, "error=login_required") || strings.Contains(location, "error=interaction_required") {
-			return nil, fmt.Errorf("session expired — run `bahn auth login` to re-authenticate")
+			return nil, ErrSessionExpired
 		}
 		return nil, fmt.Errorf("refresh failed: %w", err)
 	}
-	if returnedState != state {
-		return nil, fmt.Errorf("state mismatch during refresh")
-	}
-
-	if onStatus != nil {
-		onStatus("Exchanging code for tokens...")
-	}
-	return exchangeCode(code, verifier, realRedirectURI)
+	return &silentGrant{code: code, verifier: verifier, setCookies: resp.Cookies()}, nil
 }
 
 // --- Fragment parsing ---
 
-func extractFragmentParams(rawURL string) (code, state string, err error) {
+// errStateMismatch means a redirect carried another login's state.
+var errStateMismatch = errors.New("state mismatch: possible CSRF attack")
+
+// extractFragmentParams returns the auth code from a redirect URL's
+// fragment, or from a bare query string. Every redirect we accept comes
+// through here, so this is where its state is checked against ours.
+func extractFragmentParams(rawURL, state string) (code string, err error) {
 	var fragment string
 	if idx := strings.Index(rawURL, "#"); idx >= 0 {
 		fragment = rawURL[idx+1:]
@@ -170,20 +334,22 @@ func extractFragmentParams(rawURL string) (code, state string, err error) {
 
 	params, err := url.ParseQuery(fragment)
 	if err != nil {
-		return "", "", fmt.Errorf("invalid URL fragment: %w", err)
+		return "", fmt.Errorf("invalid URL fragment: %w", err)
+	}
+	if params.Get("state") != state {
+		return "", errStateMismatch
 	}
 
 	if errParam := params.Get("error"); errParam != "" {
 		desc := params.Get("error_description")
-		return "", "", fmt.Errorf("auth error: %s (%s)", errParam, desc)
+		return "", fmt.Errorf("auth error: %s (%s)", errParam, desc)
 	}
 
 	code = params.Get("code")
 	if code == "" {
-		return "", "", fmt.Errorf("no auth code found in URL")
+		return "", fmt.Errorf("no auth code found in URL")
 	}
-	state = params.Get("state")
-	return code, state, nil
+	return code, nil
 }
 
 // --- PKCE ---
@@ -207,33 +373,33 @@ func randomString(n int) string {
 
 // --- Auth URL ---
 
-func buildAuthURL(redirectURI, state, challenge string) string {
+func buildAuthURL(p *Provider, redirectURI, state, challenge string) string {
 	params := url.Values{
-		"client_id":             {clientID},
+		"client_id":             {p.ClientID},
 		"redirect_uri":          {redirectURI},
 		"response_type":         {"code"},
 		"response_mode":         {"fragment"},
-		"scope":                 {scopes},
+		"scope":                 {p.Scopes},
 		"state":                 {state},
 		"code_challenge":        {challenge},
 		"code_challenge_method": {"S256"},
 	}
-	return keycloakBaseURL + "/auth?" + params.Encode()
+	return p.AuthURL + "?" + params.Encode()
 }
 
 // --- Token exchange ---
 
-func exchangeCode(code, verifier, redirectURI string) (*TokenSet, error) {
+func exchangeCode(p *Provider, code, verifier, redirectURI string) (*TokenSet, error) {
 	data := url.Values{
 		"grant_type":    {"authorization_code"},
-		"client_id":     {clientID},
+		"client_id":     {p.ClientID},
 		"redirect_uri":  {redirectURI},
 		"code":          {code},
 		"code_verifier": {verifier},
 	}
 
 	resp, err := http.Post(
-		keycloakBaseURL+"/token",
+		p.TokenURL,
 		"application/x-www-form-urlencoded",
 		strings.NewReader(data.Encode()),
 	)
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/havocked/bahn-cli/internal/auth/authtest"
+)
+
+func testLoginFlow(kc *authtest.Keycloak) *loginFlow {
+	f := defaultLoginFlow(keycloakProvider(kc))
+	f.prompt = io.Discard
+	f.timeout = 5 * time.Second
+	return f
+}
+
+func TestLoginLoopback(t *testing.T) {
+	kc := authtest.NewKeycloak(t)
+	f := testLoginFlow(kc)
+	f.in = strings.NewReader("")
+	// The "browser" logs in and lets the bridge page forward the fragment.
+	f.openBrowser = func(authURL string) error {
+		q := authParams(t, authURL)
+		redirect := q.Get("redirect_uri")
+		if !strings.HasPrefix(redirect, "http://127.0.0.1:") {
+			t.Errorf("redirect_uri = %q, want loopback", redirect)
+		}
+		go func() {
+			exchange := strings.TrimSuffix(redirect, "/callback") + "/exchange?state=" + q.Get("state") + "&code=" + authtest.Code
+			resp, err := http.Get(exchange)
+			if err != nil {
+				t.Errorf("callback: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+		return nil
+	}
+
+	tokens, err := f.run(nil)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if tokens.Username != "erika" || tokens.IDToken != "id-token" {
+		t.Errorf("tokens = %+v", tokens)
+	}
+	if got := kc.TokenHits.Load(); got != 1 {
+		t.Errorf("token endpoint hits = %d, want 1", got)
+	}
+}
+
+func TestLoginLoopbackIgnoresForeignState(t *testing.T) {
+	kc := authtest.NewKeycloak(t)
+	f := testLoginFlow(kc)
+	f.in = strings.NewReader("")
+	f.openBrowser = func(authURL string) error {
+		q := authParams(t, authURL)
+		base := strings.TrimSuffix(q.Get("redirect_uri"), "/callback")
+		go func() {
+			// Another local page races the browser with its own code.
+			for _, query := range []string{
+				"state=forged&code=evil",
+				"error=access_denied",
+				"state=" + q.Get("state") + "&code=" + authtest.Code,
+			} {
+				resp, err := http.Get(base + "/exchange?" + query)
+				if err != nil {
+					t.Errorf("exchange %s: %v", query, err)
+					return
+				}
+				resp.Body.Close()
+			}
+		}()
+		return nil
+	}
+
+	tokens, err := f.run(nil)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if tokens.Username != "erika" {
+		t.Errorf("username = %q", tokens.Username)
+	}
+}
+
+func TestLoginPasteFallback(t *testing.T) {
+	kc := authtest.NewKeycloak(t)
+	kc.RejectLoopback = true
+	f := testLoginFlow(kc)
+
+	pr, pw := io.Pipe()
+	f.in = pr
+	var opened []string
+	f.openBrowser = func(authURL string) error {
+		opened = append(opened, authURL)
+		q := authParams(t, authURL)
+		resp, err := http.Get(authURL)
+		if err != nil {
+			t.Errorf("open auth page: %v", err)
+			return err
+		}
+		resp.Body.Close()
+		go fmt.Fprintf(pw, "%s#state=%s&session_state=x&code=%s\n", q.Get("redirect_uri"), q.Get("state"), authtest.Code)
+		return nil
+	}
+
+	tokens, err := f.run(nil)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(opened) != 1 {
+		t.Fatalf("browser opened %d times, want 1 (paste only)", len(opened))
+	}
+	if got := authParams(t, opened[0]).Get("redirect_uri"); got != realRedirectURI {
+		t.Errorf("paste redirect_uri = %q, want %q", got, realRedirectURI)
+	}
+	if tokens.Username != "erika" {
+		t.Errorf("username = %q", tokens.Username)
+	}
+}
+
+func TestLoginPasteStateMismatch(t *testing.T) {
+	kc := authtest.NewKeycloak(t)
+	kc.RejectLoopback = true
+	f := testLoginFlow(kc)
+	f.openBrowser = func(string) error { return nil }
+	f.in = strings.NewReader(realRedirectURI + "#state=forged&code=" + authtest.Code + "\n")
+
+	if _, err := f.run(nil); err == nil || !strings.Contains(err.Error(), "state mismatch") {
+		t.Fatalf("err = %v, want state mismatch", err)
+	}
+	if got := kc.TokenHits.Load(); got != 0 {
+		t.Errorf("token endpoint hits = %d, want 0", got)
+	}
+}
+
+func TestExchangeCodeRejectsBadVerifier(t *testing.T) {
+	kc := authtest.NewKeycloak(t)
+	p := keycloakProvider(kc)
+	_, challenge, _ := generatePKCE()
+	kc.SetChallenge(authtest.Code, challenge)
+
+	_, err := exchangeCode(p, authtest.Code, "wrong-verifier", p.RedirectURI)
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("err = %v, want invalid_grant", err)
+	}
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/havocked/bahn-cli/internal/app"
+)
+
+// Profile is the account holder as seen by bahn.de.
+type Profile struct {
+	Kundenkontoid string
+	Vorname       string
+	Nachname      string
+	ProfilArt     string // "PR" (Privat) or "GE" (Geschäftlich)
+	BahnCard      *BahnCard
+}
+
+// BahnCard is the customer's active BahnCard.
+type BahnCard struct {
+	Typ        string // e.g. "BC25", "BC50", "BC100"
+	Klasse     string // "1" or "2"
+	GueltigAb  string
+	GueltigBis string
+}
+
+// UserInfo holds the claims returned by Keycloak's userinfo endpoint.
+type UserInfo struct {
+	Sub               string `json:"sub"`
+	GivenName         string `json:"given_name"`
+	FamilyName        string `json:"family_name"`
+	Email             string `json:"email"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// userContextData is the subset of user-context-data we read.
+type userContextData struct {
+	KundenkontoID string `json:"kundenkontoId"`
+	Kundenprofile []struct {
+		ProfilArt string `json:"profilArt"`
+		Vorname   string `json:"vorname"`
+		Nachname  string `json:"nachname"`
+		BahnCards []struct {
+			Typ        string `json:"bahnCardTyp"`
+			Klasse     string `json:"klasse"`
+			GueltigAb  string `json:"gueltigAb"`
+			GueltigBis string `json:"gueltigBis"`
+		} `json:"bahnCards"`
+	} `json:"kundenprofile"`
+}
+
+// FetchUserInfo calls Keycloak's userinfo endpoint.
+func FetchUserInfo(client *http.Client) (*UserInfo, error) {
+	p, err := currentProvider()
+	if err != nil {
+		return nil, err
+	}
+	var info UserInfo
+	if err := getJSON(client, p.UserInfoURL, &info); err != nil {
+		return nil, fmt.Errorf("userinfo: %w", err)
+	}
+	return &info, nil
+}
+
+// FetchProfile combines userinfo with bahn.de's user context data.
+func FetchProfile(client *http.Client) (*Profile, error) {
+	p, err := currentProvider()
+	if err != nil {
+		return nil, err
+	}
+	info, err := FetchUserInfo(client)
+	if err != nil {
+		return nil, err
+	}
+	var data userContextData
+	if err := getJSON(client, p.UserContextURL, &data); err != nil {
+		return nil, fmt.Errorf("user-context-data: %w", err)
+	}
+
+	profile := &Profile{
+		Kundenkontoid: data.KundenkontoID,
+		Vorname:       info.GivenName,
+		Nachname:      info.FamilyName,
+	}
+	if len(data.Kundenprofile) > 0 {
+		kp := data.Kundenprofile[0]
+		profile.ProfilArt = kp.ProfilArt
+		if kp.Vorname != "" {
+			profile.Vorname = kp.Vorname
+		}
+		if kp.Nachname != "" {
+			profile.Nachname = kp.Nachname
+		}
+		if len(kp.BahnCards) > 0 {
+			bc := kp.BahnCards[0]
+			profile.BahnCard = &BahnCard{
+				Typ:        bc.Typ,
+				Klasse:     bc.Klasse,
+				GueltigAb:  bc.GueltigAb,
+				GueltigBis: bc.GueltigBis,
+			}
+		}
+	}
+	return profile, nil
+}
+
+func getJSON(client *http.Client, url string, v any) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return app.StatusError(resp.StatusCode, fmt.Errorf("status %d", resp.StatusCode))
+	}
+	return json.Unmarshal(body, v)
+}
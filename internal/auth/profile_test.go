@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/havocked/bahn-cli/internal/app"
+)
+
+// useProvider makes p the resolved provider for the test.
+func useProvider(t *testing.T, p *Provider) {
+	t.Helper()
+	providerMu.Lock()
+	resolved = p
+	providerMu.Unlock()
+	t.Cleanup(func() {
+		providerMu.Lock()
+		resolved = nil
+		providerMu.Unlock()
+	})
+}
+
+func TestFetchProfile(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/user-context-data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"sub":"user-1","given_name":"E.","family_name":"M.","preferred_username":"erika"}`))
+	})
+	mux.HandleFunc("/user-context-data", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/json" {
+			t.Errorf("Accept = %q", r.Header.Get("Accept"))
+		}
+		w.Write(fixture)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	useProvider(t, &Provider{UserInfoURL: srv.URL + "/userinfo", UserContextURL: srv.URL + "/user-context-data"})
+
+	profile, err := FetchProfile(srv.Client())
+	if err != nil {
+		t.Fatalf("FetchProfile: %v", err)
+	}
+	want := Profile{
+		Kundenkontoid: "a1b2c3d4-0000-4000-8000-123456789abc",
+		Vorname:       "Erika",
+		Nachname:      "Mustermann",
+		ProfilArt:     "PR",
+	}
+	if profile.BahnCard == nil {
+		t.Fatal("BahnCard = nil")
+	}
+	if got := *profile.BahnCard; got != (BahnCard{Typ: "BC50", Klasse: "2", GueltigAb: "2026-01-01", GueltigBis: "2026-12-31"}) {
+		t.Errorf("BahnCard = %+v", got)
+	}
+	profile.BahnCard = nil
+	if *profile != want {
+		t.Errorf("profile = %+v, want %+v", *profile, want)
+	}
+}
+
+func TestFetchProfileStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   app.Code
+		exit   int
+	}{
+		{http.StatusUnauthorized, app.CodeAuthRequired, 2},
+		{http.StatusForbidden, app.CodeAuthRequired, 2},
+		{http.StatusNotFound, app.CodeNotFound, 4},
+		{http.StatusTooManyRequests, app.CodeRateLimited, 1},
+		{http.StatusServiceUnavailable, app.CodeNetwork, 3},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/userinfo" {
+					w.Write([]byte(`{"sub":"user-1"}`))
+					return
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			useProvider(t, &Provider{UserInfoURL: srv.URL + "/userinfo", UserContextURL: srv.URL + "/user-context-data"})
+
+			_, err := FetchProfile(srv.Client())
+			if code, _, _ := app.Describe(err); code != tt.want {
+				t.Errorf("code = %s, want %s (err: %v)", code, tt.want, err)
+			}
+			if got := app.ExitCode(err); got != tt.exit {
+				t.Errorf("exit = %d, want %d", got, tt.exit)
+			}
+		})
+	}
+}
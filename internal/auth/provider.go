@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/havocked/bahn-cli/internal/config"
+)
+
+const (
+	defaultIssuer   = "https://accounts.bahn.de/auth/realms/db"
+	keycloakBaseURL = defaultIssuer + "/protocol/openid-connect"
+	clientID        = "kf_web"
+	scopes          = "openid vendo"
+	realRedirectURI = "https://www.bahn.de/.resources/bahn-common-light/webresources/assets/html/auth.v2.html"
+	userContextURL  = "https://www.bahn.de/web/api/kundenkonto/user-context-data"
+)
+
+// Provider holds the identity provider endpoints and OAuth2 client
+// parameters.
+type Provider struct {
+	Issuer        string
+	AuthURL       string
+	TokenURL      string
+	UserInfoURL   string
+	EndSessionURL string
+	RevocationURL string
+	JWKSURL       string
+	// UserContextURL is bahn.de's profile API, not part of the realm.
+	UserContextURL string
+
+	ClientID    string
+	Scopes      string
+	RedirectURI string
+}
+
+// DefaultProvider returns the bahn.de Keycloak realm.
+func DefaultProvider() *Provider {
+	return &Provider{
+		Issuer:         defaultIssuer,
+		AuthURL:        keycloakBaseURL + "/auth",
+		TokenURL:       keycloakBaseURL + "/token",
+		UserInfoURL:    keycloakBaseURL + "/userinfo",
+		EndSessionURL:  keycloakBaseURL + "/logout",
+		RevocationURL:  keycloakBaseURL + "/revoke",
+		JWKSURL:        keycloakBaseURL + "/certs",
+		UserContextURL: userContextURL,
+		ClientID:       clientID,
+		Scopes:         scopes,
+		RedirectURI:    realRedirectURI,
+	}
+}
+
+// CookieURL is the identity provider origin whose session cookies
+// authenticate a prompt=none request.
+func (p *Provider) CookieURL() string {
+	u, err := url.Parse(p.Issuer)
+	if err != nil || u.Host == "" {
+		return "https://accounts.bahn.de/"
+	}
+	return u.Scheme + "://" + u.Host + "/"
+}
+
+// Discover reads issuer's /.well-known/openid-configuration. Client
+// parameters are left at the bahn.de defaults.
+func Discover(client *http.Client, issuer string) (*Provider, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	resp, err := client.Get(issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OIDC discovery failed with status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+		EndSessionEndpoint    string `json:"end_session_endpoint"`
+		RevocationEndpoint    string `json:"revocation_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("parsing OIDC discovery document: %w", err)
+	}
+	if doc.Issuer != issuer {
+		return nil, fmt.Errorf("OIDC discovery: issuer mismatch (got %q, want %q)", doc.Issuer, issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" {
+		return nil, fmt.Errorf("OIDC discovery: missing authorization or token endpoint")
+	}
+
+	return &Provider{
+		Issuer:         doc.Issuer,
+		AuthURL:        doc.AuthorizationEndpoint,
+		TokenURL:       doc.TokenEndpoint,
+		UserInfoURL:    doc.UserInfoEndpoint,
+		EndSessionURL:  doc.EndSessionEndpoint,
+		RevocationURL:  doc.RevocationEndpoint,
+		JWKSURL:        doc.JWKSURI,
+		UserContextURL: userContextURL,
+		ClientID:       clientID,
+		Scopes:         scopes,
+		RedirectURI:    realRedirectURI,
+	}, nil
+}
+
+var (
+	providerMu  sync.Mutex
+	providerCfg config.AuthConfig
+	resolved    *Provider
+)
+
+func setProviderConfig(cfg config.AuthConfig) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	providerCfg = cfg
+	resolved = nil
+}
+
+// currentProvider resolves the configured provider once per process.
+// Without an issuer in config the bahn.de defaults are used and no
+// discovery request is made.
+func currentProvider() (*Provider, error) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if resolved != nil {
+		return resolved, nil
+	}
+
+	cfg := providerCfg
+	p := DefaultProvider()
+	if cfg.Issuer != "" && strings.TrimSuffix(cfg.Issuer, "/") != defaultIssuer {
+		var err error
+		p, err = Discover(&http.Client{Timeout: 10 * time.Second}, cfg.Issuer)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ClientID != "" {
+		p.ClientID = cfg.ClientID
+	}
+	if cfg.Scopes != "" {
+		p.Scopes = cfg.Scopes
+	}
+	if cfg.RedirectURI != "" {
+		p.RedirectURI = cfg.RedirectURI
+	}
+	if cfg.JWKSURL != "" {
+		p.JWKSURL = cfg.JWKSURL
+	}
+	resolved = p
+	return p, nil
+}
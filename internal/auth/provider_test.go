@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/havocked/bahn-cli/internal/config"
+)
+
+// newDiscoveryServer serves a well-known document built from the
+// server's own URL; edit may change it before it is written.
+func newDiscoveryServer(t *testing.T, edit func(doc map[string]string)) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			http.NotFound(w, r)
+			return
+		}
+		base := srv.URL + "/protocol/openid-connect"
+		doc := map[string]string{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": base + "/auth",
+			"token_endpoint":         base + "/token",
+			"userinfo_endpoint":      base + "/userinfo",
+			"end_session_endpoint":   base + "/logout",
+			"revocation_endpoint":    base + "/revoke",
+			"jwks_uri":               base + "/certs",
+		}
+		if edit != nil {
+			edit(doc)
+		}
+		_ = json.NewEncoder(w).Encode(doc)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDiscover(t *testing.T) {
+	srv := newDiscoveryServer(t, nil)
+	base := srv.URL + "/protocol/openid-connect"
+
+	// A trailing slash on the configured issuer is not a mismatch.
+	for _, issuer := range []string{srv.URL, srv.URL + "/"} {
+		p, err := Discover(srv.Client(), issuer)
+		if err != nil {
+			t.Fatalf("Discover(%q): %v", issuer, err)
+		}
+		want := Provider{
+			Issuer:         srv.URL,
+			AuthURL:        base + "/auth",
+			TokenURL:       base + "/token",
+			UserInfoURL:    base + "/userinfo",
+			EndSessionURL:  base + "/logout",
+			RevocationURL:  base + "/revoke",
+			JWKSURL:        base + "/certs",
+			UserContextURL: userContextURL,
+			ClientID:       clientID,
+			Scopes:         scopes,
+			RedirectURI:    realRedirectURI,
+		}
+		if *p != want {
+			t.Errorf("Discover(%q) = %+v, want %+v", issuer, *p, want)
+		}
+	}
+}
+
+func TestDiscoverRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(doc map[string]string)
+		want string
+	}{
+		{"issuer mismatch", func(doc map[string]string) { doc["issuer"] = "https://evil.test/realms/db" }, "issuer mismatch"},
+		{"missing token endpoint", func(doc map[string]string) { delete(doc, "token_endpoint") }, "missing authorization or token endpoint"},
+		{"missing authorization endpoint", func(doc map[string]string) { doc["authorization_endpoint"] = "" }, "missing authorization or token endpoint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newDiscoveryServer(t, tt.edit)
+			if _, err := Discover(srv.Client(), srv.URL); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	srv := newDiscoveryServer(t, nil)
+	if _, err := Discover(srv.Client(), srv.URL+"/realms/other"); err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Errorf("unknown realm: err = %v, want status 404", err)
+	}
+}
+
+func TestCurrentProviderConfigOverrides(t *testing.T) {
+	srv := newDiscoveryServer(t, nil)
+	setProviderConfig(config.AuthConfig{
+		Issuer:      srv.URL + "/",
+		ClientID:    "bahn-cli",
+		Scopes:      "openid",
+		RedirectURI: "http://127.0.0.1:8765/callback",
+		JWKSURL:     "https://keys.test/certs",
+	})
+	t.Cleanup(func() { setProviderConfig(config.AuthConfig{}) })
+
+	p, err := currentProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TokenURL != srv.URL+"/protocol/openid-connect/token" {
+		t.Errorf("TokenURL = %q, want the discovered endpoint", p.TokenURL)
+	}
+	if p.ClientID != "bahn-cli" || p.Scopes != "openid" || p.RedirectURI != "http://127.0.0.1:8765/callback" {
+		t.Errorf("client parameters = %q %q %q, want the config values", p.ClientID, p.Scopes, p.RedirectURI)
+	}
+	if p.JWKSURL != "https://keys.test/certs" {
+		t.Errorf("JWKSURL = %q, want the config override", p.JWKSURL)
+	}
+	if p.CookieURL() != srv.URL+"/" {
+		t.Errorf("CookieURL = %q, want the issuer origin", p.CookieURL())
+	}
+}
+
+func TestCurrentProviderDefaults(t *testing.T) {
+	t.Cleanup(func() { setProviderConfig(config.AuthConfig{}) })
+	// The bahn.de issuer, with or without a slash, needs no discovery.
+	for _, issuer := range []string{"", defaultIssuer, defaultIssuer + "/"} {
+		setProviderConfig(config.AuthConfig{Issuer: issuer, ClientID: "override"})
+		p, err := currentProvider()
+		if err != nil {
+			t.Fatalf("issuer %q: %v", issuer, err)
+		}
+		want := DefaultProvider()
+		want.ClientID = "override"
+		if *p != *want {
+			t.Errorf("issuer %q: provider = %+v, want %+v", issuer, *p, *want)
+		}
+	}
+}
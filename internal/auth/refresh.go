@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/havocked/bahn-cli/internal/app"
+)
+
+// RefreshTokens silently refreshes and saves the active account's tokens,
+// reading session cookies from src.
+// stale is what the caller saw before deciding to refresh (may be nil).
+// The load→refresh→save sequence runs under a cross-process lock, so
+// concurrent bahn invocations perform a single network refresh.
+func RefreshTokens(stale *TokenSet, src CookieSource, onStatus func(string)) (*TokenSet, error) {
+	refresh := func(onStatus func(string)) (*TokenSet, error) {
+		return RefreshFrom(src, onStatus)
+	}
+	return refreshLocked(stale, refresh, onStatus)
+}
+
+func refreshLocked(stale *TokenSet, refresh func(func(string)) (*TokenSet, error), onStatus func(string)) (*TokenSet, error) {
+	unlock, err := lockRefresh(ActiveAccount())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Someone else may have refreshed while we waited for the lock.
+	current, err := LoadTokens()
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && !current.NeedsRefresh() && (stale == nil || current.AccessToken != stale.AccessToken) {
+		if onStatus != nil {
+			onStatus("Tokens were refreshed by another process.")
+		}
+		return current, nil
+	}
+
+	tokens, err := refresh(onStatus)
+	if err != nil {
+		return nil, refreshError(err)
+	}
+	if err := SaveTokens(tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// refreshError tags a failed refresh. Keycloak refusing the session or
+// the grant, or having no session cookies to try, is an auth failure;
+// transport errors and already tagged errors are left for app's
+// classification (network is exit 3).
+func refreshError(err error) error {
+	var appErr *app.Error
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrSessionExpired), errors.Is(err, ErrNoSessionCookies):
+		return app.NewError(app.CodeSessionExpired, err)
+	case errors.As(err, &appErr), errors.As(err, &netErr):
+		return err
+	}
+	return app.NewError(app.CodeTokenExpired, err)
+}
+
+// lockRefresh locks the lock file next to the account's token file.
+func lockRefresh(account string) (func() error, error) {
+	path, err := tokensPath(account)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("locking token refresh: %w", err)
+	}
+	return unlock, nil
+}
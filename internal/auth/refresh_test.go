@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/havocked/bahn-cli/internal/app"
+	"github.com/havocked/bahn-cli/internal/auth/authtest"
+)
+
+// useTempStore keeps tokens in memory, one MemoryStore per account, and
+// points the account index and lock files at a temp config dir for the
+// duration of the test.
+func useTempStore(t *testing.T) {
+	t.Helper()
+	authtest.UseTempConfigDir(t)
+	var mu sync.Mutex
+	stores := map[string]*MemoryStore{}
+	SetStoreFactory(func(account string) Store {
+		mu.Lock()
+		defer mu.Unlock()
+		if stores[account] == nil {
+			stores[account] = NewMemoryStore()
+		}
+		return stores[account]
+	})
+	t.Cleanup(func() {
+		SetStoreFactory(func(account string) Store { return NewKeyringStore(account) })
+	})
+}
+
+func TestRefreshLockedSingleFlight(t *testing.T) {
+	useTempStore(t)
+	kc := authtest.NewKeycloak(t)
+	kc.SessionCookie = "identity"
+	p := keycloakProvider(kc)
+
+	stale := &TokenSet{
+		AccessToken: authtest.JWT(map[string]any{"exp": time.Now().Add(-time.Minute).Unix()}),
+		ExpiresAt:   time.Now().Add(-time.Minute),
+	}
+	if err := SaveTokens(stale); err != nil {
+		t.Fatal(err)
+	}
+	refresh := func(onStatus func(string)) (*TokenSet, error) {
+		return silentAuth(p, []*http.Cookie{{Name: "KEYCLOAK_IDENTITY", Value: "identity"}}, onStatus)
+	}
+
+	const n = 8
+	var wg sync.WaitGroup
+	results := make([]*TokenSet, n)
+	errs := make([]error, n)
+	for i := range n {
+		wg.Go(func() {
+			results[i], errs[i] = refreshLocked(stale, refresh, nil)
+		})
+	}
+	wg.Wait()
+
+	for i := range n {
+		if errs[i] != nil {
+			t.Fatalf("refresher %d: %v", i, errs[i])
+		}
+		if results[i].AccessToken != results[0].AccessToken {
+			t.Errorf("refresher %d got a different token", i)
+		}
+	}
+	if got := kc.TokenHits.Load(); got != 1 {
+		t.Errorf("token endpoint hits = %d, want 1", got)
+	}
+	saved, err := LoadTokens()
+	if err != nil || saved == nil || saved.AccessToken != results[0].AccessToken {
+		t.Errorf("saved tokens = %v, %v; want the refreshed set", saved, err)
+	}
+}
+
+func TestRefreshLockedErrorCodes(t *testing.T) {
+	useTempStore(t)
+	kc := authtest.NewKeycloak(t)
+	p := keycloakProvider(kc)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	tests := []struct {
+		name    string
+		refresh func(func(string)) (*TokenSet, error)
+		want    app.Code
+		exit    int
+	}{
+		{"session expired", func(onStatus func(string)) (*TokenSet, error) {
+			return silentAuth(p, []*http.Cookie{{Name: "KEYCLOAK_IDENTITY", Value: "stale"}}, onStatus)
+		}, app.CodeSessionExpired, 2},
+		{"grant rejected", func(func(string)) (*TokenSet, error) {
+			return exchangeCode(p, "unknown-code", "verifier", p.RedirectURI)
+		}, app.CodeTokenExpired, 2},
+		{"network", func(func(string)) (*TokenSet, error) {
+			return exchangeCode(&Provider{TokenURL: down.URL + "/token"}, authtest.Code, "verifier", realRedirectURI)
+		}, app.CodeNetwork, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := refreshLocked(nil, tt.refresh, nil)
+			if code, _, _ := app.Describe(err); code != tt.want {
+				t.Errorf("code = %s, want %s (err: %v)", code, tt.want, err)
+			}
+			if got := app.ExitCode(err); got != tt.exit {
+				t.Errorf("exit = %d, want %d", got, tt.exit)
+			}
+		})
+	}
+}
+
+func TestEnsureAuthRefreshed(t *testing.T) {
+	useTempStore(t)
+	kc := authtest.NewKeycloak(t)
+	kc.SessionCookie = "identity"
+	useProvider(t, keycloakProvider(kc))
+
+	fresh := &TokenSet{AccessToken: "fresh", ExpiresAt: time.Now().Add(5 * time.Minute)}
+	if err := SaveTokens(fresh); err != nil {
+		t.Fatal(err)
+	}
+	tokens, refreshed, err := EnsureAuthRefreshed()
+	if err != nil || refreshed || tokens.AccessToken != "fresh" {
+		t.Fatalf("fresh: tokens=%v refreshed=%v err=%v", tokens, refreshed, err)
+	}
+
+	stale := &TokenSet{
+		AccessToken:    "stale",
+		ExpiresAt:      time.Now().Add(10 * time.Second),
+		SessionCookies: []SessionCookie{{Name: "KEYCLOAK_IDENTITY", Value: "identity"}},
+	}
+	if err := SaveTokens(stale); err != nil {
+		t.Fatal(err)
+	}
+	tokens, refreshed, err = EnsureAuthRefreshed()
+	if err != nil || !refreshed || tokens.Username != "erika" {
+		t.Fatalf("stale: tokens=%v refreshed=%v err=%v", tokens, refreshed, err)
+	}
+
+	// A failed refresh hands back the stored tokens with the error.
+	useEmptyCookieJar(t)
+	kc.SessionCookie = "other"
+	stale.AccessToken = "stale-again"
+	if err := SaveTokens(stale); err != nil {
+		t.Fatal(err)
+	}
+	tokens, refreshed, err = EnsureAuthRefreshed()
+	if err == nil || refreshed || tokens == nil || tokens.AccessToken != "stale-again" {
+		t.Fatalf("failed: tokens=%v refreshed=%v err=%v", tokens, refreshed, err)
+	}
+}
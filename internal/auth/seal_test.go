@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSealRoundTrip(t *testing.T) {
+	data, err := seal([]byte("secret"), []byte(`{"accessToken":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := unseal([]byte("secret"), data)
+	if err != nil {
+		t.Fatalf("unseal: %v", err)
+	}
+	if string(got) != `{"accessToken":"x"}` {
+		t.Errorf("plaintext = %s", got)
+	}
+	if _, err := unseal([]byte("wrong"), data); err == nil {
+		t.Error("unseal with wrong secret succeeded")
+	}
+}
+
+func TestUnsealRejectsIterations(t *testing.T) {
+	data, err := seal([]byte("secret"), []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{0, 1, sealedIterations - 1, 1 << 40} {
+		var f sealedFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			t.Fatal(err)
+		}
+		f.Iterations = n
+		tampered, _ := json.Marshal(f)
+		if _, err := unseal([]byte("secret"), tampered); err == nil || !strings.Contains(err.Error(), "iteration count") {
+			t.Errorf("iterations %d: err = %v", n, err)
+		}
+	}
+}
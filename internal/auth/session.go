@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/havocked/bahn-cli/internal/app"
+)
+
+// errNotAuthenticated is returned when no tokens are stored.
+var errNotAuthenticated = errors.New("not authenticated — run `bahn auth login` or `bahn auth token <jwt>`")
+
+// Client returns an authenticated http.Client.
+// Handles transparent token refresh.
+func Client() (*http.Client, error) {
+	tokens, err := EnsureAuth()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: newTransport(tokens)}, nil
+}
+
+// EnsureAuth loads the stored tokens and refreshes them if they are about
+// to expire. Fails with exit code 2 if no usable session is left.
+func EnsureAuth() (*TokenSet, error) {
+	tokens, _, err := EnsureAuthRefreshed()
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// EnsureAuthRefreshed is EnsureAuth that also reports whether the stored
+// tokens were replaced by a refresh. If the refresh fails, the stored
+// tokens are returned along with the error, for callers that can use
+// them until they actually expire.
+func EnsureAuthRefreshed() (tokens *TokenSet, refreshed bool, err error) {
+	stored, err := LoadTokens()
+	if err != nil {
+		return nil, false, err
+	}
+	if stored == nil {
+		return nil, false, app.NewError(app.CodeAuthRequired, errNotAuthenticated)
+	}
+	if !stored.NeedsRefresh() {
+		return stored, false, nil
+	}
+	fresh, err := refreshLocked(stored, Refresh, nil)
+	if err != nil {
+		return stored, false, err
+	}
+	return fresh, true, nil
+}
+
+// transport injects the bearer token and retries once after a refresh
+// when the server answers 401.
+type transport struct {
+	base    http.RoundTripper
+	refresh func(func(string)) (*TokenSet, error)
+
+	mu     sync.Mutex
+	tokens *TokenSet
+}
+
+func newTransport(tokens *TokenSet) *transport {
+	return &transport{
+		base:    http.DefaultTransport,
+		refresh: Refresh,
+		tokens:  tokens,
+	}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tokens, err := t.current()
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(withBearer(req, tokens.AccessToken))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	// Bodies without GetBody cannot be replayed.
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	tokens, err = t.renew(tokens)
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+	retry := withBearer(req, tokens.AccessToken)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			closeBody(req)
+			return nil, fmt.Errorf("replaying request body: %w", err)
+		}
+		retry.Body = body
+	}
+	return t.base.RoundTrip(retry)
+}
+
+// closeBody closes the request body on error paths. The RoundTripper
+// contract requires it even when the base transport was never reached.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+// current returns tokens that are valid for at least the refresh margin.
+func (t *transport) current() (*TokenSet, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.tokens.NeedsRefresh() {
+		return t.tokens, nil
+	}
+	tokens, err := refreshLocked(t.tokens, t.refresh, nil)
+	if err != nil {
+		return nil, err
+	}
+	t.tokens = tokens
+	return tokens, nil
+}
+
+// renew forces a refresh after a 401, unless another request already
+// replaced the rejected tokens.
+func (t *transport) renew(rejected *TokenSet) (*TokenSet, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tokens.AccessToken != rejected.AccessToken {
+		return t.tokens, nil
+	}
+	tokens, err := refreshLocked(rejected, t.refresh, nil)
+	if err != nil {
+		return nil, err
+	}
+	t.tokens = tokens
+	return tokens, nil
+}
+
+func withBearer(req *http.Request, accessToken string) *http.Request {
+	out := req.Clone(req.Context())
+	out.Header.Set("Authorization", "Bearer "+accessToken)
+	return out
+}
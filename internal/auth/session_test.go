@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/havocked/bahn-cli/internal/app"
+	"github.com/havocked/bahn-cli/internal/auth/authtest"
+)
+
+// fakeAPI is an API server that only accepts the bearer tokens issued
+// by authtest.Keycloak. It records the request bodies it sees.
+type fakeAPI struct {
+	*httptest.Server
+
+	// alwaysReject answers 401 to every request.
+	alwaysReject bool
+
+	hits   atomic.Int32
+	mu     sync.Mutex
+	bodies []string
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	t.Helper()
+	api := &fakeAPI{}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.hits.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		api.mu.Lock()
+		api.bodies = append(api.bodies, string(body))
+		api.mu.Unlock()
+
+		claims, err := ParseJWT(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if api.alwaysReject || err != nil || claims.PreferredUsername != "erika" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// useRejectedSession stores tokens that the API rejects but that are
+// not yet due for a refresh, with the session cookie kc accepts.
+func useRejectedSession(t *testing.T, kc *authtest.Keycloak) *TokenSet {
+	t.Helper()
+	useTempStore(t)
+	kc.SessionCookie = "identity"
+	useProvider(t, keycloakProvider(kc))
+	useEmptyCookieJar(t)
+
+	tokens := &TokenSet{
+		AccessToken:    authtest.JWT(map[string]any{"preferred_username": "revoked"}),
+		ExpiresAt:      time.Now().Add(5 * time.Minute),
+		SessionCookies: []SessionCookie{{Name: "KEYCLOAK_IDENTITY", Value: "identity"}},
+	}
+	if err := SaveTokens(tokens); err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+// useEmptyCookieJar makes the fallback cookie source an empty jar
+// instead of the real browsers.
+func useEmptyCookieJar(t *testing.T) {
+	t.Helper()
+	jar := filepath.Join(t.TempDir(), "cookies.txt")
+	if err := os.WriteFile(jar, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	setCookieSource(CookieSource{CookiesFile: jar})
+	t.Cleanup(func() { setCookieSource(CookieSource{}) })
+}
+
+func TestTransportRetriesOnceAfterRefresh(t *testing.T) {
+	kc := authtest.NewKeycloak(t)
+	client := &http.Client{Transport: newTransport(useRejectedSession(t, kc))}
+	api := newFakeAPI(t)
+
+	resp, err := client.Post(api.URL, "application/json", strings.NewReader(`{"q":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := api.hits.Load(); got != 2 {
+		t.Errorf("API hits = %d, want 2", got)
+	}
+	if got := kc.TokenHits.Load(); got != 1 {
+		t.Errorf("token endpoint hits = %d, want 1", got)
+	}
+	for i, body := range api.bodies {
+		if body != `{"q":1}` {
+			t.Errorf("attempt %d body = %q, want the original body replayed", i+1, body)
+		}
+	}
+}
+
+func TestTransportRetriesAtMostOnce(t *testing.T) {
+	kc := authtest.NewKeycloak(t)
+	client := &http.Client{Transport: newTransport(useRejectedSession(t, kc))}
+	api := newFakeAPI(t)
+	api.alwaysReject = true
+
+	resp, err := client.Get(api.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+	if got := api.hits.Load(); got != 2 {
+		t.Errorf("API hits = %d, want 2", got)
+	}
+}
+
+func TestTransportSkipsRetryForUnreplayableBody(t *testing.T) {
+	kc := authtest.NewKeycloak(t)
+	client := &http.Client{Transport: newTransport(useRejectedSession(t, kc))}
+	api := newFakeAPI(t)
+
+	// Wrapping the reader hides it from NewRequest, so GetBody stays nil.
+	req, err := http.NewRequest(http.MethodPost, api.URL, io.NopCloser(strings.NewReader("once")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want the 401 passed through", resp.StatusCode)
+	}
+	if got := api.hits.Load(); got != 1 {
+		t.Errorf("API hits = %d, want 1", got)
+	}
+	if got := kc.TokenHits.Load(); got != 0 {
+		t.Errorf("token endpoint hits = %d, want 0", got)
+	}
+}
+
+func TestTransportConcurrentRenewsRefreshOnce(t *testing.T) {
+	kc := authtest.NewKeycloak(t)
+	client := &http.Client{Transport: newTransport(useRejectedSession(t, kc))}
+	api := newFakeAPI(t)
+
+	const n = 8
+	var wg sync.WaitGroup
+	status := make([]int, n)
+	for i := range n {
+		wg.Go(func() {
+			resp, err := client.Get(api.URL)
+			if err != nil {
+				t.Errorf("request %d: %v", i, err)
+				return
+			}
+			resp.Body.Close()
+			status[i] = resp.StatusCode
+		})
+	}
+	wg.Wait()
+
+	for i, s := range status {
+		if s != http.StatusOK {
+			t.Errorf("request %d status = %d, want 200", i, s)
+		}
+	}
+	if got := kc.TokenHits.Load(); got != 1 {
+		t.Errorf("token endpoint hits = %d, want 1", got)
+	}
+}
+
+func TestTransportSessionGone(t *testing.T) {
+	kc := authtest.NewKeycloak(t)
+	client := &http.Client{Transport: newTransport(useRejectedSession(t, kc))}
+	kc.SessionCookie = "logged-out-elsewhere"
+	api := newFakeAPI(t)
+
+	_, err := client.Get(api.URL)
+	if code, _, _ := app.Describe(err); code != app.CodeSessionExpired {
+		t.Errorf("code = %s, want %s (err: %v)", code, app.CodeSessionExpired, err)
+	}
+	if got := app.ExitCode(err); got != 2 {
+		t.Errorf("exit = %d, want 2", got)
+	}
+}
+
+// trackedBody records whether it was closed.
+type trackedBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestTransportClosesBodyOnError(t *testing.T) {
+	kc := authtest.NewKeycloak(t)
+	useRejectedSession(t, kc)
+	kc.SessionCookie = "logged-out-elsewhere"
+	expired := &TokenSet{AccessToken: "expired", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := SaveTokens(expired); err != nil {
+		t.Fatal(err)
+	}
+	tr := newTransport(expired)
+
+	body := &trackedBody{Reader: strings.NewReader("payload")}
+	req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1:1/", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.RoundTrip(req); err == nil {
+		t.Fatal("RoundTrip succeeded without a usable session")
+	}
+	if !body.closed {
+		t.Error("request body not closed on error")
+	}
+}
+
+func TestEnsureAuthNotAuthenticated(t *testing.T) {
+	useTempStore(t)
+	_, err := EnsureAuth()
+	if code, _, _ := app.Describe(err); code != app.CodeAuthRequired {
+		t.Errorf("code = %s, want %s", code, app.CodeAuthRequired)
+	}
+	if got := app.ExitCode(err); got != 2 {
+		t.Errorf("exit = %d, want 2", got)
+	}
+}
@@ -0,0 +1,409 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/havocked/bahn-cli/internal/config"
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	StoreKeyring = "keyring"
+	StoreFile    = "file"
+
+	// Storage formats reported by StorageFormat.
+	FormatKeyring       = "keyring"
+	FormatFile          = "file"
+	FormatEncryptedFile = "encrypted-file"
+
+	keyringService = "bahn-cli"
+	keyringUser    = "tokens"
+)
+
+// Store persists a TokenSet. Load returns nil, nil when nothing is stored.
+type Store interface {
+	Load() (*TokenSet, error)
+	Save(tokens *TokenSet) error
+	Clear() error
+}
+
+var (
+	storeMu  sync.Mutex
+	newStore = func(account string) Store { return NewKeyringStore(account) }
+	// selectedAccount is set by --account; empty means the current
+	// account from the index.
+	selectedAccount string
+)
+
+// SetStoreFactory replaces the backend used by SaveTokens, LoadTokens and
+// ClearTokens. The factory is called on every access, so stateful
+// backends must hand out the same store for an account each time.
+func SetStoreFactory(f func(account string) Store) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	newStore = f
+}
+
+func storeFor(account string) Store {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	return newStore(account)
+}
+
+func currentStore() Store {
+	return storeFor(ActiveAccount())
+}
+
+// formatReporter is implemented by stores that can tell how the current
+// tokens are persisted.
+type formatReporter interface {
+	Format() string
+}
+
+// StorageFormat reports how an account's tokens are persisted: "keyring",
+// "file" or "encrypted-file". Empty if nothing is stored.
+func StorageFormat(account string) string {
+	if r, ok := storeFor(account).(formatReporter); ok {
+		return r.Format()
+	}
+	return ""
+}
+
+// Configure selects the token store from the [auth] config section and
+// the account to operate on (empty for the current one).
+// The token file is encrypted when a passphrase or key file is set.
+func Configure(cfg config.AuthConfig, account string) error {
+	if account != "" {
+		if err := validateAccountName(account); err != nil {
+			return err
+		}
+	}
+	secret, err := tokenSecret(cfg.KeyFile)
+	if err != nil {
+		return err
+	}
+	src := CookieSource{Browser: cfg.Browser, Profile: cfg.Profile, CookiesFile: cfg.CookiesFile}
+	if err := src.Validate(); err != nil {
+		return err
+	}
+
+	var factory func(string) Store
+	switch cfg.Store {
+	case StoreKeyring:
+		factory = func(account string) Store {
+			ks := NewKeyringStore(account)
+			ks.Fallback = &FileStore{Account: account, Secret: secret}
+			return ks
+		}
+	case "", StoreFile:
+		factory = func(account string) Store {
+			return &FileStore{Account: account, Secret: secret}
+		}
+	default:
+		return fmt.Errorf("unknown token store %q (want %q or %q)", cfg.Store, StoreKeyring, StoreFile)
+	}
+
+	SetStoreFactory(factory)
+	setProviderConfig(cfg)
+	resetVerifier()
+	setCookieSource(src)
+	storeMu.Lock()
+	selectedAccount = account
+	storeMu.Unlock()
+	return nil
+}
+
+// SaveTokens stores the token set for the active account.
+func SaveTokens(tokens *TokenSet) error {
+	account := ActiveAccount()
+	if err := storeFor(account).Save(tokens); err != nil {
+		return err
+	}
+	return registerAccount(account)
+}
+
+// LoadTokens reads the stored token set of the active account.
+func LoadTokens() (*TokenSet, error) {
+	return currentStore().Load()
+}
+
+// ClearTokens removes stored tokens of the active account. The account
+// stays known and current; `bahn auth accounts remove` forgets it.
+func ClearTokens() error {
+	return storeFor(ActiveAccount()).Clear()
+}
+
+// tokensPath returns ~/.config/bahn-cli/tokens.json for the default
+// account and tokens-<account>.json for the others.
+func tokensPath(account string) (string, error) {
+	dir, err := config.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	name := "tokens.json"
+	if account != "" && account != DefaultAccount {
+		name = "tokens-" + account + ".json"
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// writeFileAtomic writes data to a temp file in the same directory and
+// renames it over path, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// --- File ---
+
+// FileStore keeps tokens in a 0600 JSON file. With a Secret the file is
+// sealed with AES-GCM; Load accepts both formats.
+type FileStore struct {
+	Account string
+	// Path overrides the account's default token file when set.
+	Path   string
+	Secret []byte
+}
+
+// NewFileStore returns a FileStore at the account's default path.
+func NewFileStore(account string) *FileStore {
+	return &FileStore{Account: account}
+}
+
+func (s *FileStore) path() (string, error) {
+	if s.Path != "" {
+		return s.Path, nil
+	}
+	return tokensPath(s.Account)
+}
+
+func (s *FileStore) Save(tokens *TokenSet) error {
+	path, err := s.path()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if s.Secret != nil {
+		if data, err = seal(s.Secret, data); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(path, data, 0o600)
+}
+
+func (s *FileStore) Load() (*TokenSet, error) {
+	path, err := s.path()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if isSealed(data) {
+		if s.Secret == nil {
+			return nil, errNoTokenKey
+		}
+		if data, err = unseal(s.Secret, data); err != nil {
+			return nil, err
+		}
+	}
+	var tokens TokenSet
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	return &tokens, nil
+}
+
+func (s *FileStore) Format() string {
+	path, err := s.path()
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	if isSealed(data) {
+		return FormatEncryptedFile
+	}
+	return FormatFile
+}
+
+func (s *FileStore) Clear() error {
+	path, err := s.path()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// --- Keyring ---
+
+// KeyringStore keeps tokens in the OS keyring (Keychain, Secret Service,
+// Windows Credential Manager). When the keyring is unavailable or the
+// secret is too big for it, tokens go to Fallback instead. A token file
+// left over from before the keyring was used is migrated on first load.
+type KeyringStore struct {
+	Service  string
+	User     string
+	Fallback Store
+}
+
+// NewKeyringStore returns a KeyringStore for account that falls back to
+// the account's token file.
+func NewKeyringStore(account string) *KeyringStore {
+	user := keyringUser
+	if account != "" && account != DefaultAccount {
+		user += "/" + account
+	}
+	return &KeyringStore{
+		Service:  keyringService,
+		User:     user,
+		Fallback: NewFileStore(account),
+	}
+}
+
+func (s *KeyringStore) Save(tokens *TokenSet) error {
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	if err := keyring.Set(s.Service, s.User, string(data)); err != nil {
+		if s.Fallback == nil {
+			return fmt.Errorf("keyring: %w", err)
+		}
+		return s.Fallback.Save(tokens)
+	}
+	// Don't leave a stale plain-text copy behind.
+	if s.Fallback != nil {
+		_ = s.Fallback.Clear()
+	}
+	return nil
+}
+
+func (s *KeyringStore) Load() (*TokenSet, error) {
+	secret, err := keyring.Get(s.Service, s.User)
+	switch {
+	case err == nil:
+		var tokens TokenSet
+		if err := json.Unmarshal([]byte(secret), &tokens); err != nil {
+			return nil, fmt.Errorf("keyring: %w", err)
+		}
+		return &tokens, nil
+	case s.Fallback == nil && errors.Is(err, keyring.ErrNotFound):
+		return nil, nil
+	case s.Fallback == nil:
+		return nil, fmt.Errorf("keyring: %w", err)
+	}
+
+	tokens, ferr := s.Fallback.Load()
+	if ferr != nil || tokens == nil {
+		return tokens, ferr
+	}
+	if errors.Is(err, keyring.ErrNotFound) {
+		// Keyring works but is empty: migrate the file into it.
+		_ = s.Save(tokens)
+	}
+	return tokens, nil
+}
+
+func (s *KeyringStore) Format() string {
+	if _, err := keyring.Get(s.Service, s.User); err == nil {
+		return FormatKeyring
+	}
+	if r, ok := s.Fallback.(formatReporter); ok {
+		return r.Format()
+	}
+	return ""
+}
+
+func (s *KeyringStore) Clear() error {
+	err := keyring.Delete(s.Service, s.User)
+	if errors.Is(err, keyring.ErrNotFound) {
+		err = nil
+	}
+	if err != nil {
+		err = fmt.Errorf("keyring: %w", err)
+	}
+	if s.Fallback != nil {
+		return errors.Join(err, s.Fallback.Clear())
+	}
+	return err
+}
+
+// --- Memory ---
+
+// MemoryStore keeps tokens in process memory. Intended for tests.
+type MemoryStore struct {
+	mu     sync.Mutex
+	tokens *TokenSet
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Save(tokens *TokenSet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *tokens
+	s.tokens = &copied
+	return nil
+}
+
+func (s *MemoryStore) Load() (*TokenSet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens == nil {
+		return nil, nil
+	}
+	copied := *s.tokens
+	return &copied, nil
+}
+
+func (s *MemoryStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = nil
+	return nil
+}
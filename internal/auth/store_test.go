@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/go-keyring"
+)
+
+func testTokens(access string) *TokenSet {
+	return &TokenSet{AccessToken: access, ExpiresAt: time.Now().Add(5 * time.Minute).Truncate(time.Second)}
+}
+
+// fileExists reports whether the account's token file is on disk.
+func fileExists(t *testing.T, account string) bool {
+	t.Helper()
+	path, err := tokensPath(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+func TestKeyringStoreMigratesFile(t *testing.T) {
+	useTempStore(t)
+	keyring.MockInit()
+	if err := NewFileStore(DefaultAccount).Save(testTokens("legacy")); err != nil {
+		t.Fatal(err)
+	}
+
+	ks := NewKeyringStore(DefaultAccount)
+	tokens, err := ks.Load()
+	if err != nil || tokens == nil || tokens.AccessToken != "legacy" {
+		t.Fatalf("Load = %v, %v; want the file's tokens", tokens, err)
+	}
+	if _, err := keyring.Get(keyringService, keyringUser); err != nil {
+		t.Errorf("tokens not migrated into the keyring: %v", err)
+	}
+	if fileExists(t, DefaultAccount) {
+		t.Error("token file left behind after migration")
+	}
+	if got := ks.Format(); got != FormatKeyring {
+		t.Errorf("Format = %q, want %q", got, FormatKeyring)
+	}
+}
+
+func TestKeyringStoreSaveRemovesFile(t *testing.T) {
+	useTempStore(t)
+	keyring.MockInit()
+	if err := NewFileStore("work").Save(testTokens("stale")); err != nil {
+		t.Fatal(err)
+	}
+
+	ks := NewKeyringStore("work")
+	if err := ks.Save(testTokens("fresh")); err != nil {
+		t.Fatal(err)
+	}
+	if fileExists(t, "work") {
+		t.Error("plain-text token file left next to the keyring entry")
+	}
+	if _, err := keyring.Get(keyringService, keyringUser+"/work"); err != nil {
+		t.Errorf("keyring entry for work: %v", err)
+	}
+	tokens, err := ks.Load()
+	if err != nil || tokens == nil || tokens.AccessToken != "fresh" {
+		t.Errorf("Load = %v, %v; want the saved tokens", tokens, err)
+	}
+}
+
+func TestKeyringStoreFallsBackToFile(t *testing.T) {
+	useTempStore(t)
+	keyring.MockInitWithError(errors.New("no secret service"))
+	t.Cleanup(keyring.MockInit)
+
+	ks := NewKeyringStore(DefaultAccount)
+	if err := ks.Save(testTokens("on-disk")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if !fileExists(t, DefaultAccount) {
+		t.Fatal("tokens not written to the fallback file")
+	}
+	tokens, err := ks.Load()
+	if err != nil || tokens == nil || tokens.AccessToken != "on-disk" {
+		t.Errorf("Load = %v, %v; want the file's tokens", tokens, err)
+	}
+	if got := ks.Format(); got != FormatFile {
+		t.Errorf("Format = %q, want %q", got, FormatFile)
+	}
+	// The keyring error is reported even though the file was removed:
+	// tokens may still sit in a keyring that is only temporarily locked.
+	if err := ks.Clear(); err == nil || !strings.Contains(err.Error(), "no secret service") {
+		t.Errorf("Clear: err = %v, want the keyring error", err)
+	}
+	if fileExists(t, DefaultAccount) {
+		t.Error("Clear left the fallback file")
+	}
+}
+
+func TestKeyringStoreWithoutFallback(t *testing.T) {
+	keyring.MockInitWithError(errors.New("no secret service"))
+	t.Cleanup(keyring.MockInit)
+
+	ks := &KeyringStore{Service: keyringService, User: keyringUser}
+	if err := ks.Save(testTokens("x")); err == nil {
+		t.Error("Save succeeded without keyring or fallback")
+	}
+	if _, err := ks.Load(); err == nil {
+		t.Error("Load succeeded without keyring or fallback")
+	}
+}
+
+func TestKeyringStoreClear(t *testing.T) {
+	useTempStore(t)
+	keyring.MockInit()
+
+	ks := NewKeyringStore(DefaultAccount)
+	if err := ks.Save(testTokens("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if _, err := keyring.Get(keyringService, keyringUser); !errors.Is(err, keyring.ErrNotFound) {
+		t.Errorf("keyring entry after Clear: err = %v, want ErrNotFound", err)
+	}
+	tokens, err := ks.Load()
+	if err != nil || tokens != nil {
+		t.Errorf("Load after Clear = %v, %v; want nil, nil", tokens, err)
+	}
+	// Clearing twice is not an error.
+	if err := ks.Clear(); err != nil {
+		t.Errorf("second Clear: %v", err)
+	}
+}
@@ -5,12 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
-
-	"github.com/havocked/bahn-cli/internal/config"
 )
 
 // TokenSet holds the current authentication tokens.
@@ -22,17 +18,34 @@ type TokenSet struct {
 	Kundenkontoid string    `json:"kundenkontoid"`
 	Sub           string    `json:"sub"`
 	Username      string    `json:"username"`
+	Roles         []string  `json:"roles,omitempty"`
+	Groups        []string  `json:"groups,omitempty"`
+	Scopes        []string  `json:"scopes,omitempty"`
+	AuthMethods   []string  `json:"authMethods,omitempty"`
+	SessionID     string    `json:"sessionId,omitempty"`
+	// AuthTime is when the user last entered credentials, i.e. when the
+	// Keycloak session started.
+	AuthTime       time.Time       `json:"authTime,omitzero"`
+	SessionCookies []SessionCookie `json:"sessionCookies,omitempty"`
 }
 
 // Claims represents parsed JWT claims we care about.
 type Claims struct {
 	Exp               int64    `json:"exp"`
 	Iat               int64    `json:"iat"`
+	Iss               string   `json:"iss"`
+	Aud               audience `json:"aud"`
 	Sub               string   `json:"sub"`
 	Kundenkontoid     string   `json:"kundenkontoid"`
 	PreferredUsername string   `json:"preferred_username"`
 	Scope             string   `json:"scope"`
 	Groups            []string `json:"groups"`
+	Amr               []string `json:"amr"`
+	Sid               string   `json:"sid"`
+	AuthTime          int64    `json:"auth_time"`
+	RealmAccess       struct {
+		Roles []string `json:"roles"`
+	} `json:"realm_access"`
 }
 
 // IsExpired returns true if the token is past its expiry.
@@ -45,6 +58,15 @@ func (t *TokenSet) NeedsRefresh() bool {
 	return time.Now().After(t.ExpiresAt.Add(-30 * time.Second))
 }
 
+// SessionAge estimates how long the Keycloak session has been alive.
+// Zero if the token carries no auth_time.
+func (t *TokenSet) SessionAge() time.Duration {
+	if t.AuthTime.IsZero() {
+		return 0
+	}
+	return time.Since(t.AuthTime)
+}
+
 // TimeRemaining returns how long until the token expires.
 func (t *TokenSet) TimeRemaining() time.Duration {
 	return time.Until(t.ExpiresAt)
@@ -82,69 +104,60 @@ func TokenSetFromJWT(accessToken string) (*TokenSet, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &TokenSet{
+	tokens := &TokenSet{
 		AccessToken:   accessToken,
 		ExpiresAt:     time.Unix(claims.Exp, 0),
 		Kundenkontoid: claims.Kundenkontoid,
 		Sub:           claims.Sub,
 		Username:      claims.PreferredUsername,
-	}, nil
-}
-
-// tokensPath returns ~/.config/bahn-cli/tokens.json
-func tokensPath() (string, error) {
-	dir, err := config.ConfigDir()
-	if err != nil {
-		return "", err
+		Roles:         claims.RealmAccess.Roles,
+		Groups:        claims.Groups,
+		Scopes:        strings.Fields(claims.Scope),
+		AuthMethods:   claims.Amr,
+		SessionID:     claims.Sid,
+	}
+	if claims.AuthTime != 0 {
+		tokens.AuthTime = time.Unix(claims.AuthTime, 0)
 	}
-	return filepath.Join(dir, "tokens.json"), nil
+	return tokens, nil
 }
 
-// SaveTokens stores the token set to disk.
-func SaveTokens(tokens *TokenSet) error {
-	path, err := tokensPath()
-	if err != nil {
-		return err
+// ParseTokenInput builds a TokenSet from pasted input: a bare JWT, or the
+// bahn.de sessionStorage["token"] JSON blob ({accessToken, idToken}) as
+// copied from DevTools, optionally still wrapped in console quotes.
+func ParseTokenInput(input string) (*TokenSet, error) {
+	s := strings.TrimSpace(input)
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		s = strings.TrimSpace(s[1 : len(s)-1])
 	}
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return err
+	if strings.HasPrefix(s, `"`) {
+		var inner string
+		if err := json.Unmarshal([]byte(s), &inner); err != nil {
+			return nil, fmt.Errorf("parsing quoted token: %w", err)
+		}
+		s = strings.TrimSpace(inner)
 	}
-	data, err := json.MarshalIndent(tokens, "", "  ")
-	if err != nil {
-		return err
+	if s == "" {
+		return nil, errors.New("empty token input")
+	}
+	if !strings.HasPrefix(s, "{") {
+		return TokenSetFromJWT(s)
 	}
-	return os.WriteFile(path, data, 0o600)
-}
 
-// LoadTokens reads the stored token set from disk.
-func LoadTokens() (*TokenSet, error) {
-	path, err := tokensPath()
-	if err != nil {
-		return nil, err
+	var blob struct {
+		AccessToken string `json:"accessToken"`
+		IDToken     string `json:"idToken"`
 	}
-	data, err := os.ReadFile(path)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil, nil
-		}
-		return nil, err
+	if err := json.Unmarshal([]byte(s), &blob); err != nil {
+		return nil, fmt.Errorf("parsing token JSON: %w", err)
 	}
-	var tokens TokenSet
-	if err := json.Unmarshal(data, &tokens); err != nil {
-		return nil, err
+	if blob.AccessToken == "" {
+		return nil, errors.New("token JSON has no accessToken")
 	}
-	return &tokens, nil
-}
-
-// ClearTokens removes stored tokens.
-func ClearTokens() error {
-	path, err := tokensPath()
+	tokens, err := TokenSetFromJWT(blob.AccessToken)
 	if err != nil {
-		return err
-	}
-	err = os.Remove(path)
-	if errors.Is(err, os.ErrNotExist) {
-		return nil
+		return nil, err
 	}
-	return err
+	tokens.IDToken = blob.IDToken
+	return tokens, nil
 }
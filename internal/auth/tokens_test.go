@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/havocked/bahn-cli/internal/auth/authtest"
+)
+
+func TestParseTokenInput(t *testing.T) {
+	exp := time.Now().Add(5 * time.Minute).Unix()
+	jwt := authtest.JWT(map[string]any{
+		"exp":                exp,
+		"sub":                "user-1",
+		"preferred_username": "erika",
+		"kundenkontoid":      "kk-1",
+	})
+	blob := `{"accessToken":"` + jwt + `","idToken":"id-token","refreshToken":null}`
+	quoted, _ := json.Marshal(blob)
+
+	tests := []struct {
+		name    string
+		input   string
+		idToken string
+	}{
+		{"bare JWT", jwt, ""},
+		{"bare JWT with whitespace", "  " + jwt + "\n", ""},
+		{"sessionStorage blob", blob, "id-token"},
+		{"console-quoted blob", "'" + blob + "'", "id-token"},
+		{"JSON-quoted blob", string(quoted), "id-token"},
+		{"JSON-quoted JWT", `"` + jwt + `"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := ParseTokenInput(tt.input)
+			if err != nil {
+				t.Fatalf("ParseTokenInput: %v", err)
+			}
+			if tokens.AccessToken != jwt || tokens.IDToken != tt.idToken {
+				t.Errorf("tokens = %q / %q, want the JWT and id token %q", tokens.AccessToken, tokens.IDToken, tt.idToken)
+			}
+			if tokens.Sub != "user-1" || tokens.Username != "erika" || tokens.Kundenkontoid != "kk-1" {
+				t.Errorf("claims = %+v", tokens)
+			}
+			if tokens.ExpiresAt.Unix() != exp {
+				t.Errorf("ExpiresAt = %s, want %s", tokens.ExpiresAt, time.Unix(exp, 0))
+			}
+		})
+	}
+}
+
+func TestParseTokenInputErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "  \n", "empty token input"},
+		{"empty quotes", "''", "empty token input"},
+		{"missing accessToken", `{"idToken":"id-token"}`, "no accessToken"},
+		{"bad JSON", `{"accessToken":`, "parsing token JSON"},
+		{"bad quoted string", `"unterminated`, "parsing quoted token"},
+		{"not a JWT", "hello", "expected 3 parts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseTokenInput(tt.input); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/havocked/bahn-cli/internal/app"
+	"github.com/havocked/bahn-cli/internal/auth"
+)
+
+type AuthAccountsCmd struct {
+	List   AuthAccountsListCmd   `kong:"cmd,default='1',help='List known accounts.'"`
+	Use    AuthAccountsUseCmd    `kong:"cmd,help='Switch the current account.'"`
+	Remove AuthAccountsRemoveCmd `kong:"cmd,help='Delete an account and its tokens.'"`
+}
+
+// --- auth accounts list ---
+
+type AuthAccountsListCmd struct{}
+
+type accountPayload struct {
+	Name          string `json:"name" human:"Account"`
+	Current       bool   `json:"current" human:"Current"`
+	Username      string `json:"username,omitempty" human:"User"`
+	Kundenkontoid string `json:"kundenkontoid,omitempty" human:"Kundenkonto"`
+	ExpiresAt     string `json:"expiresAt,omitempty" human:"Expires"`
+}
+
+func (cmd *AuthAccountsListCmd) Run(ctx *app.Context) error {
+	accounts, err := auth.Accounts()
+	if err != nil {
+		return err
+	}
+
+	stream := ctx.Output.Stream()
+	for _, acc := range accounts {
+		entry := accountPayload{Name: acc.Name, Current: acc.Current}
+		if acc.Tokens != nil {
+			entry.Username = acc.Tokens.Username
+			entry.Kundenkontoid = acc.Tokens.Kundenkontoid
+			entry.ExpiresAt = acc.Tokens.ExpiresAt.Format(time.RFC3339)
+		}
+		if err := stream.Send(entry, nil); err != nil {
+			return err
+		}
+	}
+	return stream.Close([]string{"No accounts. Run `bahn auth login`."})
+}
+
+// accountChangePayload is the result of use and remove.
+type accountChangePayload struct {
+	Status  string `json:"status" human:"Status"`
+	Current string `json:"current,omitempty" human:"Current,omitempty"`
+	Removed string `json:"removed,omitempty" human:"Removed,omitempty"`
+}
+
+// --- auth accounts use ---
+
+type AuthAccountsUseCmd struct {
+	Name string `arg:"" help:"Account name."`
+}
+
+func (cmd *AuthAccountsUseCmd) Run(ctx *app.Context) error {
+	if err := auth.UseAccount(cmd.Name); err != nil {
+		return err
+	}
+	return ctx.Output.Emit(accountChangePayload{Status: "ok", Current: cmd.Name}, nil)
+}
+
+// --- auth accounts remove ---
+
+type AuthAccountsRemoveCmd struct {
+	Name string `arg:"" help:"Account name."`
+}
+
+func (cmd *AuthAccountsRemoveCmd) Run(ctx *app.Context) error {
+	if err := auth.RemoveAccount(cmd.Name); err != nil {
+		return err
+	}
+	return ctx.Output.Emit(accountChangePayload{Status: "ok", Removed: cmd.Name}, nil)
+}
@@ -1,7 +1,12 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/havocked/bahn-cli/internal/app"
@@ -9,98 +14,249 @@ import (
 )
 
 type AuthCmd struct {
-	Login   AuthLoginCmd   `kong:"cmd,help='Authenticate via browser (OIDC flow).'"`
-	Status  AuthStatusCmd  `kong:"cmd,help='Show current auth state.'"`
-	Token   AuthTokenCmd   `kong:"cmd,help='Manually set a JWT token.'"`
-	Refresh AuthRefreshCmd `kong:"cmd,help='Silently refresh the access token.'"`
-	Clear   AuthClearCmd   `kong:"cmd,help='Remove stored credentials.'"`
+	Login      AuthLoginCmd      `kong:"cmd,help='Authenticate via browser (OIDC flow).'"`
+	Status     AuthStatusCmd     `kong:"cmd,help='Show auth state of all accounts.'"`
+	Token      AuthTokenCmd      `kong:"cmd,help='Manually set a JWT token.'"`
+	Refresh    AuthRefreshCmd    `kong:"cmd,help='Silently refresh the access token.'"`
+	Clear      AuthClearCmd      `kong:"cmd,help='Remove stored credentials.'"`
+	Logout     AuthLogoutCmd     `kong:"cmd,help='Revoke tokens, end the Keycloak session and clear credentials.'"`
+	Accounts   AuthAccountsCmd   `kong:"cmd,help='Manage named accounts.'"`
+	Can        AuthCanCmd        `kong:"cmd,help='Check that the current login carries realm roles.'"`
+	KeepAlive  AuthKeepAliveCmd  `kong:"cmd,name='keep-alive',help='Keep tokens fresh in the foreground until stopped.'"`
+	Doctor     AuthDoctorCmd     `kong:"cmd,help='Diagnose token, cookie and Keycloak session problems.'"`
+	PrintToken AuthPrintTokenCmd `kong:"cmd,name='print-token',help='Print a fresh access token for other tools.'"`
+}
+
+// tokenPayload is the result of commands that store new tokens.
+type tokenPayload struct {
+	Status        string `json:"status" human:"Status"`
+	Username      string `json:"username" human:"User"`
+	Kundenkontoid string `json:"kundenkontoid" human:"Kundenkonto"`
+	ExpiresAt     string `json:"expiresAt" human:"Expires"`
+	Remaining     string `json:"remaining" human:"Valid for"`
+}
+
+func newTokenPayload(tokens *auth.TokenSet) tokenPayload {
+	return tokenPayload{
+		Status:        "ok",
+		Username:      tokens.Username,
+		Kundenkontoid: tokens.Kundenkontoid,
+		ExpiresAt:     tokens.ExpiresAt.Format(time.RFC3339),
+		Remaining:     tokens.TimeRemaining().Round(time.Second).String(),
+	}
+}
+
+// okPayload is the result of commands with nothing else to report.
+type okPayload struct {
+	Status string `json:"status" human:"Status"`
 }
 
 // --- auth status ---
 
-type AuthStatusCmd struct{}
+type AuthStatusCmd struct {
+	Remote bool `help:"Fetch name, profile type and BahnCard from bahn.de for the current account."`
+}
 
 type authStatusPayload struct {
-	Authenticated bool    `json:"authenticated"`
-	Username      string  `json:"username,omitempty"`
-	Kundenkontoid string  `json:"kundenkontoid,omitempty"`
-	Sub           string  `json:"sub,omitempty"`
-	ExpiresAt     string  `json:"expiresAt,omitempty"`
-	Expired       bool    `json:"expired,omitempty"`
-	Remaining     string  `json:"remaining,omitempty"`
+	Account       string   `json:"account" human:"Account"`
+	Current       bool     `json:"current" human:"Current"`
+	Authenticated bool     `json:"authenticated" human:"Valid"`
+	Username      string   `json:"username,omitempty" human:"User,omitempty"`
+	Kundenkontoid string   `json:"kundenkontoid,omitempty" human:"Kundenkonto,omitempty"`
+	Sub           string   `json:"sub,omitempty" human:"-"`
+	ExpiresAt     string   `json:"expiresAt,omitempty" human:"-"`
+	Expired       bool     `json:"expired,omitempty" human:"-"`
+	Remaining     string   `json:"remaining,omitempty" human:"Remaining,omitempty"`
+	Roles         []string `json:"roles,omitempty" human:"-"`
+	Groups        []string `json:"groups,omitempty" human:"-"`
+	Scopes        []string `json:"scopes,omitempty" human:"-"`
+	AuthMethods   []string `json:"authMethods,omitempty" human:"Auth,omitempty"`
+	SessionID     string   `json:"sessionId,omitempty" human:"-"`
+	SessionAge    string   `json:"sessionAge,omitempty" human:"Session age,omitempty"`
+	Cookies       int      `json:"sessionCookies,omitempty" human:"Cookies,omitempty"`
+	Storage       string   `json:"storage,omitempty" human:"Storage,omitempty"`
+	Error         string   `json:"error,omitempty" human:"Error,omitempty"`
+
+	// Filled by --remote for the current account.
+	FirstName   string           `json:"firstName,omitempty" human:"First name,omitempty"`
+	LastName    string           `json:"lastName,omitempty" human:"Last name,omitempty"`
+	ProfilArt   string           `json:"profilArt,omitempty" human:"Profile,omitempty"`
+	BahnCard    *bahnCardPayload `json:"bahnCard,omitempty" human:"BahnCard,omitempty"`
+	RemoteError string           `json:"remoteError,omitempty" human:"Remote error,omitempty"`
+}
+
+type bahnCardPayload struct {
+	Type       string `json:"type"`
+	Class      string `json:"class,omitempty"`
+	ValidFrom  string `json:"validFrom,omitempty"`
+	ValidUntil string `json:"validUntil,omitempty"`
+}
+
+// String is the card's human table cell.
+func (bc bahnCardPayload) String() string {
+	return fmt.Sprintf("%s, class %s, valid %s – %s", bc.Type, bc.Class, bc.ValidFrom, bc.ValidUntil)
 }
 
 func (cmd *AuthStatusCmd) Run(ctx *app.Context) error {
-	tokens, err := auth.LoadTokens()
+	if cmd.Remote {
+		ctx.Output.SetSource("bahn.de", false)
+	} else {
+		ctx.Output.SetSource("token-store", true)
+	}
+	accounts, err := auth.Accounts()
 	if err != nil {
 		return err
 	}
-	if tokens == nil {
-		return ctx.Output.Emit(
-			authStatusPayload{Authenticated: false},
-			[]string{"Not authenticated. Run `bahn auth login` or `bahn auth token <jwt>`."},
-		)
+	if !slices.ContainsFunc(accounts, func(a auth.Account) bool { return a.Current }) {
+		accounts = append(accounts, auth.Account{Name: auth.ActiveAccount(), Current: true})
+	}
+
+	stream := ctx.Output.Stream()
+	for _, acc := range accounts {
+		entry := accountStatus(acc)
+		if cmd.Remote && acc.Current && acc.Tokens != nil {
+			remoteStatus(ctx, &entry)
+		}
+		if err := stream.Send(entry, nil); err != nil {
+			return err
+		}
+	}
+	return stream.Close(nil)
+}
+
+func accountStatus(acc auth.Account) authStatusPayload {
+	entry := authStatusPayload{Account: acc.Name, Current: acc.Current}
+	switch {
+	case acc.Err != nil:
+		entry.Error = acc.Err.Error()
+		return entry
+	case acc.Tokens == nil:
+		return entry
 	}
 
-	remaining := tokens.TimeRemaining()
+	tokens := acc.Tokens
 	expired := tokens.IsExpired()
 	remainingStr := ""
 	if !expired {
-		remainingStr = remaining.Round(time.Second).String()
+		remainingStr = tokens.TimeRemaining().Round(time.Second).String()
 	}
 
-	payload := authStatusPayload{
-		Authenticated: !expired,
-		Username:      tokens.Username,
-		Kundenkontoid: tokens.Kundenkontoid,
-		Sub:           tokens.Sub,
-		ExpiresAt:     tokens.ExpiresAt.Format(time.RFC3339),
-		Expired:       expired,
-		Remaining:     remainingStr,
+	entry.Authenticated = !expired
+	entry.Username = tokens.Username
+	entry.Kundenkontoid = tokens.Kundenkontoid
+	entry.Sub = tokens.Sub
+	entry.ExpiresAt = tokens.ExpiresAt.Format(time.RFC3339)
+	entry.Expired = expired
+	entry.Remaining = remainingStr
+	entry.Roles = tokens.Roles
+	entry.Groups = tokens.Groups
+	entry.Scopes = tokens.Scopes
+	entry.AuthMethods = tokens.AuthMethods
+	entry.SessionID = tokens.SessionID
+	entry.Cookies = len(tokens.SessionCookies)
+	if age := tokens.SessionAge(); age > 0 {
+		entry.SessionAge = age.Round(time.Minute).String()
 	}
+	entry.Storage = auth.StorageFormat(acc.Name)
+	return entry
+}
 
-	human := []string{
-		fmt.Sprintf("User: %s", tokens.Username),
-		fmt.Sprintf("Account: %s", tokens.Kundenkontoid),
+// remoteStatus adds the bahn.de profile to entry.
+func remoteStatus(ctx *app.Context, entry *authStatusPayload) {
+	client, err := auth.Client()
+	var profile *auth.Profile
+	if err == nil {
+		profile, err = auth.FetchProfile(client)
 	}
-	if expired {
-		human = append(human, "Token: expired")
-	} else {
-		human = append(human, fmt.Sprintf("Token: valid (%s remaining)", remainingStr))
+	if err != nil {
+		ctx.Output.Infof("remote profile: %v", err)
+		entry.RemoteError = err.Error()
+		return
 	}
 
-	return ctx.Output.Emit(payload, human)
+	entry.FirstName = profile.Vorname
+	entry.LastName = profile.Nachname
+	entry.ProfilArt = profile.ProfilArt
+	if bc := profile.BahnCard; bc != nil {
+		entry.BahnCard = &bahnCardPayload{
+			Type:       bc.Typ,
+			Class:      bc.Klasse,
+			ValidFrom:  bc.GueltigAb,
+			ValidUntil: bc.GueltigBis,
+		}
+	}
 }
 
 // --- auth token (manual) ---
 
 type AuthTokenCmd struct {
-	JWT string `arg:"" help:"JWT access token to store."`
+	JWT      string `arg:"" optional:"" help:"JWT or sessionStorage token JSON to store; '-' reads stdin."`
+	FromFile string `help:"Read the JWT or token JSON from a file." type:"path"`
+	Verify   bool   `help:"Check the signature and claims against the realm's JWKS."`
 }
 
 func (cmd *AuthTokenCmd) Run(ctx *app.Context) error {
-	tokens, err := auth.TokenSetFromJWT(cmd.JWT)
+	input, err := cmd.input()
+	if err != nil {
+		return err
+	}
+	tokens, err := auth.ParseTokenInput(input)
 	if err != nil {
-		return fmt.Errorf("invalid JWT: %w", err)
+		return app.Errorf(app.CodeInvalidInput, "invalid token: %w", err)
+	}
+	if cmd.Verify || ctx.Config.Auth.Verify {
+		if err := verifyToken(ctx, tokens.AccessToken); err != nil {
+			return err
+		}
+	}
+	// The session cookies captured at login are what silent refresh
+	// replays; keep them while the pasted token is for the same user.
+	if prev, _ := auth.LoadTokens(); prev != nil && prev.Sub != "" && prev.Sub == tokens.Sub {
+		tokens.SessionCookies = prev.SessionCookies
 	}
 	if err := auth.SaveTokens(tokens); err != nil {
 		return err
 	}
+	ctx.Output.Infof("Note: pasted tokens have a 5 min lifetime. Use `bahn auth login` for persistent auth.")
+	return ctx.Output.Emit(newTokenPayload(tokens), nil)
+}
 
-	remaining := tokens.TimeRemaining().Round(time.Second)
-	payload := map[string]any{
-		"status":        "ok",
-		"username":      tokens.Username,
-		"kundenkontoid": tokens.Kundenkontoid,
-		"expiresAt":     tokens.ExpiresAt.Format(time.RFC3339),
-		"remaining":     remaining.String(),
+// input returns the token text from the argument, stdin or --from-file.
+func (cmd *AuthTokenCmd) input() (string, error) {
+	var r io.Reader
+	switch {
+	case cmd.JWT != "" && cmd.FromFile != "":
+		return "", app.Errorf(app.CodeInvalidInput, "pass a token or --from-file, not both")
+	case cmd.FromFile != "":
+		f, err := os.Open(cmd.FromFile)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		r = f
+	case cmd.JWT == "-":
+		r = os.Stdin
+	case cmd.JWT != "":
+		return cmd.JWT, nil
+	default:
+		return "", app.Errorf(app.CodeInvalidInput, "no token given — pass it as an argument, '-' for stdin, or --from-file")
 	}
-	human := []string{
-		fmt.Sprintf("✓ Authenticated as %s", tokens.Username),
-		fmt.Sprintf("  Token expires in %s", remaining),
-		"  Note: token has 5 min lifetime. Use `bahn auth login` for persistent auth.",
+	data, err := io.ReadAll(io.LimitReader(r, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("reading token: %w", err)
 	}
-	return ctx.Output.Emit(payload, human)
+	return string(data), nil
+}
+
+// verifyToken reports verification failures as a structured
+// invalid_token error.
+func verifyToken(ctx *app.Context, jwt string) error {
+	_, err := auth.VerifyJWT(jwt)
+	var tokErr *auth.InvalidTokenError
+	if !errors.As(err, &tokErr) {
+		return err
+	}
+	return app.NewError(app.CodeInvalidToken, err)
 }
 
 // --- auth login (OIDC) ---
@@ -111,6 +267,7 @@ func (cmd *AuthLoginCmd) Run(ctx *app.Context) error {
 	onStatus := func(msg string) {
 		ctx.Output.Infof("%s", msg)
 	}
+	ctx.Output.SetSource("keycloak", false)
 	tokens, err := auth.Login(onStatus)
 	if err != nil {
 		return err
@@ -118,52 +275,48 @@ func (cmd *AuthLoginCmd) Run(ctx *app.Context) error {
 	if err := auth.SaveTokens(tokens); err != nil {
 		return err
 	}
-
-	remaining := tokens.TimeRemaining().Round(time.Second)
-	payload := map[string]any{
-		"status":        "ok",
-		"username":      tokens.Username,
-		"kundenkontoid": tokens.Kundenkontoid,
-		"expiresAt":     tokens.ExpiresAt.Format(time.RFC3339),
-		"remaining":     remaining.String(),
-	}
-	human := []string{
-		fmt.Sprintf("✓ Authenticated as %s", tokens.Username),
-		fmt.Sprintf("  Account: %s", tokens.Kundenkontoid),
-		fmt.Sprintf("  Token valid for %s", remaining),
-	}
-	return ctx.Output.Emit(payload, human)
+	return ctx.Output.Emit(newTokenPayload(tokens), nil)
 }
 
 // --- auth refresh ---
 
-type AuthRefreshCmd struct{}
+type AuthRefreshCmd struct {
+	cookieFlags
+}
+
+// cookieFlags override the [auth] cookie source for one invocation.
+type cookieFlags struct {
+	Browser     string `help:"Read session cookies from this browser only (chrome, firefox, safari, ...)."`
+	Profile     string `help:"Browser profile name or path (requires --browser)."`
+	CookiesFile string `help:"Read session cookies from a Netscape/curl cookie jar." type:"path"`
+}
+
+func (f cookieFlags) source() (auth.CookieSource, error) {
+	src := auth.ConfiguredCookieSource()
+	if f.Browser != "" || f.Profile != "" || f.CookiesFile != "" {
+		src = auth.CookieSource{Browser: f.Browser, Profile: f.Profile, CookiesFile: f.CookiesFile}
+	}
+	return src, app.NewError(app.CodeInvalidInput, src.Validate())
+}
 
 func (cmd *AuthRefreshCmd) Run(ctx *app.Context) error {
 	onStatus := func(msg string) {
 		ctx.Output.Infof("%s", msg)
 	}
-	tokens, err := auth.Refresh(onStatus)
+	current, err := auth.LoadTokens()
 	if err != nil {
-		return app.WrapExit(2, err)
-	}
-	if err := auth.SaveTokens(tokens); err != nil {
 		return err
 	}
-
-	remaining := tokens.TimeRemaining().Round(time.Second)
-	payload := map[string]any{
-		"status":        "ok",
-		"username":      tokens.Username,
-		"kundenkontoid": tokens.Kundenkontoid,
-		"expiresAt":     tokens.ExpiresAt.Format(time.RFC3339),
-		"remaining":     remaining.String(),
+	src, err := cmd.source()
+	if err != nil {
+		return err
 	}
-	human := []string{
-		fmt.Sprintf("✓ Token refreshed for %s", tokens.Username),
-		fmt.Sprintf("  Valid for %s", remaining),
+	ctx.Output.SetSource("keycloak", false)
+	tokens, err := auth.RefreshTokens(current, src, onStatus)
+	if err != nil {
+		return err
 	}
-	return ctx.Output.Emit(payload, human)
+	return ctx.Output.Emit(newTokenPayload(tokens), nil)
 }
 
 // --- auth clear ---
@@ -174,8 +327,201 @@ func (cmd *AuthClearCmd) Run(ctx *app.Context) error {
 	if err := auth.ClearTokens(); err != nil {
 		return err
 	}
-	return ctx.Output.Emit(
-		map[string]string{"status": "ok"},
-		[]string{"Credentials cleared."},
+	return ctx.Output.Emit(okPayload{Status: "ok"}, nil)
+}
+
+// --- auth logout ---
+
+type AuthLogoutCmd struct{}
+
+type logoutStep struct {
+	Step    string `json:"step" human:"Step"`
+	OK      bool   `json:"ok" human:"OK"`
+	Skipped bool   `json:"skipped,omitempty" human:"Skipped,omitempty"`
+	Error   string `json:"error,omitempty" human:"Error,omitempty"`
+}
+
+type logoutPayload struct {
+	Status string       `json:"status" human:"Status"`
+	Steps  []logoutStep `json:"steps" human:"Steps"`
+}
+
+func (cmd *AuthLogoutCmd) Run(ctx *app.Context) error {
+	// Unreadable tokens only skip the server steps; we still clear.
+	tokens, loadErr := auth.LoadTokens()
+
+	remote := func(name string, call func(*auth.TokenSet) error, have bool) logoutStep {
+		step := logoutStep{Step: name}
+		switch {
+		case loadErr != nil:
+			step.Skipped, step.Error = true, loadErr.Error()
+		case !have:
+			step.Skipped = true
+		default:
+			if err := call(tokens); err != nil {
+				step.Error = err.Error()
+				ctx.Output.Infof("%s: %v", name, err)
+			} else {
+				step.OK = true
+			}
+		}
+		return step
+	}
+
+	payload := logoutPayload{Status: "ok"}
+	payload.Steps = append(payload.Steps,
+		remote("revoke", auth.Revoke, tokens != nil && tokens.AccessToken != ""),
+		remote("logout", auth.EndSession, tokens != nil && tokens.IDToken != ""),
 	)
+	if err := auth.ClearTokens(); err != nil {
+		return err
+	}
+	payload.Steps = append(payload.Steps, logoutStep{Step: "clear", OK: true})
+
+	// A skipped step is fine unless it was skipped because of an error.
+	for _, step := range payload.Steps {
+		if step.Error != "" || (!step.OK && !step.Skipped) {
+			payload.Status = "partial"
+		}
+	}
+	return ctx.Output.Emit(payload, nil)
+}
+
+// --- auth can ---
+
+type AuthCanCmd struct {
+	Roles []string `arg:"" help:"Realm roles to check, e.g. bue_buchen."`
+}
+
+type authCanPayload struct {
+	Allowed bool     `json:"allowed" human:"Allowed"`
+	Roles   []string `json:"roles" human:"Roles"`
+	Missing []string `json:"missing,omitempty" human:"Missing,omitempty"`
+}
+
+func (cmd *AuthCanCmd) Run(ctx *app.Context) error {
+	tokens, err := auth.EnsureAuth()
+	if err != nil {
+		return err
+	}
+	// Roles come from the token itself, not what was stored alongside it.
+	claims, err := auth.ParseJWT(tokens.AccessToken)
+	if err != nil {
+		return app.NewError(app.CodeInvalidToken, err)
+	}
+
+	payload := authCanPayload{Roles: cmd.Roles}
+	for _, role := range cmd.Roles {
+		if !slices.Contains(claims.RealmAccess.Roles, role) {
+			payload.Missing = append(payload.Missing, role)
+		}
+	}
+	payload.Allowed = len(payload.Missing) == 0
+
+	if err := ctx.Output.Emit(payload, nil); err != nil {
+		return err
+	}
+	if payload.Allowed {
+		return nil
+	}
+	return app.Reported(app.WrapExit(1, fmt.Errorf("missing role(s): %s", strings.Join(payload.Missing, ", "))))
+}
+
+// --- auth doctor ---
+
+type AuthDoctorCmd struct{}
+
+// Run prints every check. The exit code follows the last check, which
+// is the verdict: 2 when the session cannot be used, 3 when Keycloak is
+// unreachable. Missing cookies in some browsers alone do not fail it.
+func (cmd *AuthDoctorCmd) Run(ctx *app.Context) error {
+	ctx.Output.SetSource("keycloak", false)
+	stream := ctx.Output.Stream()
+	var verdict auth.Check
+	var sendErr error
+	auth.Diagnose(func(c auth.Check) {
+		verdict = c
+		if sendErr == nil {
+			sendErr = stream.Send(c, nil)
+		}
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err := stream.Close(nil); err != nil {
+		return err
+	}
+	if verdict.OK {
+		return nil
+	}
+	err := fmt.Errorf("%s check failed: %s", verdict.Check, verdict.Detail)
+	switch verdict.Check {
+	case "keycloak":
+		return app.Reported(app.WrapExit(3, err))
+	case "prompt_none":
+		return app.Reported(app.WrapExit(2, err))
+	}
+	return app.Reported(err)
+}
+
+// --- auth print-token ---
+
+type AuthPrintTokenCmd struct {
+	As string `help:"Token format: raw, header, env or json." enum:"raw,header,env,json" default:"raw"`
+}
+
+// printTokenPayload is the --as json output.
+type printTokenPayload struct {
+	AccessToken string `json:"accessToken"`
+	TokenType   string `json:"tokenType"`
+	ExpiresAt   string `json:"expiresAt"`
+	Remaining   string `json:"remaining"`
+}
+
+func (cmd *AuthPrintTokenCmd) Run(ctx *app.Context) error {
+	// raw, header and env are for other tools and cannot carry meta.
+	if ctx.Settings.Envelope && cmd.As != "json" {
+		return app.Errorf(app.CodeInvalidInput, "--envelope needs --as json, not --as %s", cmd.As)
+	}
+	tokens, refreshed, err := auth.EnsureAuthRefreshed()
+	switch {
+	case err == nil && tokens.IsExpired():
+		// Keycloak handed out a token that is already stale.
+		return app.Errorf(app.CodeTokenExpired, "access token expired at %s", tokens.ExpiresAt.Format(time.RFC3339))
+	case err == nil:
+	case tokens == nil:
+		return err
+	case tokens.IsExpired():
+		// An expired token is an auth failure whatever broke the
+		// refresh, so even a network error exits 2.
+		if app.ExitCode(err) == 2 {
+			return err
+		}
+		return app.NewError(app.CodeTokenExpired, fmt.Errorf("access token expired at %s and refresh failed: %w", tokens.ExpiresAt.Format(time.RFC3339), err))
+	default:
+		// A failed refresh is fine while the old token still works.
+		ctx.Output.Infof("refresh failed, printing current token: %v", err)
+	}
+	if refreshed {
+		ctx.Output.SetSource("keycloak", false)
+	} else {
+		ctx.Output.SetSource("token-store", true)
+	}
+
+	switch cmd.As {
+	case "header":
+		_, err = fmt.Fprintf(ctx.Output.Out, "Authorization: Bearer %s\n", tokens.AccessToken)
+	case "env":
+		_, err = fmt.Fprintf(ctx.Output.Out, "BAHN_ACCESS_TOKEN=%s\n", tokens.AccessToken)
+	case "json":
+		err = ctx.Output.JSON(printTokenPayload{
+			AccessToken: tokens.AccessToken,
+			TokenType:   "Bearer",
+			ExpiresAt:   tokens.ExpiresAt.Format(time.RFC3339),
+			Remaining:   tokens.TimeRemaining().Round(time.Second).String(),
+		})
+	default:
+		_, err = fmt.Fprintln(ctx.Output.Out, tokens.AccessToken)
+	}
+	return err
 }
@@ -0,0 +1,278 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/havocked/bahn-cli/internal/app"
+	"github.com/havocked/bahn-cli/internal/auth"
+	"github.com/havocked/bahn-cli/internal/auth/authtest"
+	"github.com/havocked/bahn-cli/internal/config"
+	"github.com/havocked/bahn-cli/internal/output"
+)
+
+// useFakeRealm stores tokens in a temp config dir and points the auth
+// package at a fresh fake Keycloak realm.
+func useFakeRealm(t *testing.T) *authtest.Keycloak {
+	t.Helper()
+	authtest.UseTempConfigDir(t)
+	realm := authtest.NewKeycloak(t)
+	if err := auth.Configure(config.AuthConfig{Store: auth.StoreFile, Issuer: realm.URL}, ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = auth.Configure(config.AuthConfig{}, "") })
+	return realm
+}
+
+// testContext returns a Context whose stdout is the returned buffer.
+func testContext(format output.Format) (*app.Context, *bytes.Buffer) {
+	var out bytes.Buffer
+	return &app.Context{
+		Config: config.Default(),
+		Output: output.New(output.Options{Format: format, Out: &out, Err: io.Discard}),
+	}, &out
+}
+
+func saveTestTokens(t *testing.T, tokens *auth.TokenSet) {
+	t.Helper()
+	if err := auth.SaveTokens(tokens); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runLogout(t *testing.T) logoutPayload {
+	t.Helper()
+	ctx, out := testContext(output.FormatJSON)
+	if err := (&AuthLogoutCmd{}).Run(ctx); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	var payload logoutPayload
+	if err := json.Unmarshal(out.Bytes(), &payload); err != nil {
+		t.Fatalf("logout output: %v\n%s", err, out)
+	}
+	if tokens, err := auth.LoadTokens(); err != nil || tokens != nil {
+		t.Errorf("tokens after logout = %v, %v; want cleared", tokens, err)
+	}
+	return payload
+}
+
+func TestAuthLogout(t *testing.T) {
+	realm := useFakeRealm(t)
+	saveTestTokens(t, &auth.TokenSet{AccessToken: "access", IDToken: "id", ExpiresAt: time.Now().Add(time.Minute)})
+
+	payload := runLogout(t)
+	if payload.Status != "ok" {
+		t.Errorf("status = %q, want ok (steps %+v)", payload.Status, payload.Steps)
+	}
+	for _, step := range payload.Steps {
+		if !step.OK {
+			t.Errorf("step %+v not ok", step)
+		}
+	}
+
+	if got := realm.Revoked().Get("token"); got != "access" {
+		t.Errorf("revoked token = %q, want access", got)
+	}
+	if got := realm.Revoked().Get("token_type_hint"); got != "access_token" {
+		t.Errorf("token_type_hint = %q, want access_token", got)
+	}
+	if got := realm.EndSession().Get("id_token_hint"); got != "id" {
+		t.Errorf("id_token_hint = %q, want id", got)
+	}
+}
+
+func TestAuthLogoutServerDown(t *testing.T) {
+	realm := useFakeRealm(t)
+	saveTestTokens(t, &auth.TokenSet{AccessToken: "access", IDToken: "id", ExpiresAt: time.Now().Add(time.Minute)})
+	realm.Close()
+
+	payload := runLogout(t)
+	if payload.Status != "partial" {
+		t.Errorf("status = %q, want partial", payload.Status)
+	}
+	want := map[string]bool{"revoke": false, "logout": false, "clear": true}
+	for _, step := range payload.Steps {
+		if step.OK != want[step.Step] {
+			t.Errorf("step %+v, want ok=%v", step, want[step.Step])
+		}
+	}
+}
+
+func TestAuthLogoutUnreadableTokens(t *testing.T) {
+	realm := useFakeRealm(t)
+	// A sealed token file without the passphrase cannot be read.
+	cfg := config.AuthConfig{Store: auth.StoreFile, Issuer: realm.URL}
+	t.Setenv(auth.EnvTokenPassphrase, "secret")
+	if err := auth.Configure(cfg, ""); err != nil {
+		t.Fatal(err)
+	}
+	saveTestTokens(t, &auth.TokenSet{AccessToken: "access", ExpiresAt: time.Now().Add(time.Minute)})
+	t.Setenv(auth.EnvTokenPassphrase, "")
+	if err := auth.Configure(cfg, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := runLogout(t)
+	if payload.Status != "partial" {
+		t.Errorf("status = %q, want partial", payload.Status)
+	}
+	for _, step := range payload.Steps[:2] {
+		if !step.Skipped || step.Error == "" {
+			t.Errorf("step %+v, want skipped with the load error", step)
+		}
+	}
+}
+
+func TestAuthTokenKeepsSessionCookies(t *testing.T) {
+	useFakeRealm(t)
+	cookies := []auth.SessionCookie{{Name: "KEYCLOAK_IDENTITY", Value: "identity"}}
+	jwt := func(sub string) string {
+		return authtest.JWT(map[string]any{"sub": sub, "exp": time.Now().Add(5 * time.Minute).Unix()})
+	}
+
+	tests := []struct {
+		name    string
+		sub     string
+		cookies int
+	}{
+		{"same user", "user-1", 1},
+		{"other user", "user-2", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saveTestTokens(t, &auth.TokenSet{
+				AccessToken:    jwt("user-1"),
+				Sub:            "user-1",
+				ExpiresAt:      time.Now().Add(time.Minute),
+				SessionCookies: cookies,
+			})
+			ctx, _ := testContext(output.FormatJSON)
+			if err := (&AuthTokenCmd{JWT: jwt(tt.sub)}).Run(ctx); err != nil {
+				t.Fatal(err)
+			}
+			stored, err := auth.LoadTokens()
+			if err != nil || stored == nil {
+				t.Fatalf("LoadTokens = %v, %v", stored, err)
+			}
+			if stored.Sub != tt.sub || len(stored.SessionCookies) != tt.cookies {
+				t.Errorf("stored sub %q with %d cookies, want %q with %d", stored.Sub, len(stored.SessionCookies), tt.sub, tt.cookies)
+			}
+		})
+	}
+}
+
+func TestAuthPrintToken(t *testing.T) {
+	useFakeRealm(t)
+	jwt := authtest.JWT(map[string]any{"sub": "user-1"})
+	saveTestTokens(t, &auth.TokenSet{AccessToken: jwt, ExpiresAt: time.Now().Add(5 * time.Minute)})
+
+	tests := []struct {
+		as   string
+		want string
+	}{
+		{"raw", jwt + "\n"},
+		{"header", "Authorization: Bearer " + jwt + "\n"},
+		{"env", "BAHN_ACCESS_TOKEN=" + jwt + "\n"},
+	}
+	for _, tt := range tests {
+		ctx, out := testContext(output.FormatJSON)
+		if err := (&AuthPrintTokenCmd{As: tt.as}).Run(ctx); err != nil {
+			t.Fatalf("--as %s: %v", tt.as, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("--as %s = %q, want %q", tt.as, out.String(), tt.want)
+		}
+	}
+
+	ctx, out := testContext(output.FormatJSON)
+	if err := (&AuthPrintTokenCmd{As: "json"}).Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var payload printTokenPayload
+	if err := json.Unmarshal(out.Bytes(), &payload); err != nil || payload.AccessToken != jwt || payload.TokenType != "Bearer" {
+		t.Errorf("--as json = %s (%v)", out, err)
+	}
+}
+
+func TestAuthPrintTokenExpiredExits2(t *testing.T) {
+	realm := useFakeRealm(t)
+	saveTestTokens(t, &auth.TokenSet{
+		AccessToken:    authtest.JWT(map[string]any{"sub": "user-1"}),
+		ExpiresAt:      time.Now().Add(-time.Minute),
+		SessionCookies: []auth.SessionCookie{{Name: "KEYCLOAK_IDENTITY", Value: "identity"}},
+	})
+	// Keycloak is unreachable, so the refresh fails with a network error.
+	realm.Close()
+
+	ctx, out := testContext(output.FormatJSON)
+	err := (&AuthPrintTokenCmd{As: "raw"}).Run(ctx)
+	if code, msg, _ := app.Describe(err); code != app.CodeTokenExpired || !strings.Contains(msg, "refresh failed") {
+		t.Errorf("error = %s: %s, want token_expired with the refresh failure", code, msg)
+	}
+	if got := app.ExitCode(err); got != 2 {
+		t.Errorf("exit = %d, want 2", got)
+	}
+	if out.Len() != 0 {
+		t.Errorf("printed %q for an expired token", out.String())
+	}
+}
+
+func TestAuthPrintTokenEnvelope(t *testing.T) {
+	useFakeRealm(t)
+	saveTestTokens(t, &auth.TokenSet{AccessToken: authtest.JWT(map[string]any{}), ExpiresAt: time.Now().Add(5 * time.Minute)})
+
+	for _, as := range []string{"raw", "header", "env"} {
+		ctx, out := testContext(output.FormatJSON)
+		ctx.Settings.Envelope = true
+		err := (&AuthPrintTokenCmd{As: as}).Run(ctx)
+		if code, _, _ := app.Describe(err); code != app.CodeInvalidInput {
+			t.Errorf("--as %s --envelope: code = %s, want invalid_input", as, code)
+		}
+		if out.Len() != 0 {
+			t.Errorf("--as %s --envelope printed %q", as, out.String())
+		}
+	}
+
+	var out bytes.Buffer
+	ctx := &app.Context{
+		Config:   config.Default(),
+		Settings: app.Settings{Envelope: true},
+		Output:   output.New(output.Options{Out: &out, Err: io.Discard, Envelope: true}),
+	}
+	if err := (&AuthPrintTokenCmd{As: "json"}).Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Data printTokenPayload `json:"data"`
+		Meta output.Meta       `json:"meta"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil || got.Data.TokenType != "Bearer" || got.Meta.Source != "token-store" {
+		t.Errorf("--as json --envelope = %s (%v)", out.String(), err)
+	}
+}
+
+func TestAuthCanRefreshesExpiredToken(t *testing.T) {
+	realm := useFakeRealm(t)
+	realm.SessionCookie = "identity"
+	saveTestTokens(t, &auth.TokenSet{
+		AccessToken:    authtest.JWT(map[string]any{"sub": "user-1"}),
+		ExpiresAt:      time.Now().Add(-time.Minute),
+		SessionCookies: []auth.SessionCookie{{Name: "KEYCLOAK_IDENTITY", Value: "identity"}},
+	})
+
+	ctx, out := testContext(output.FormatJSON)
+	if err := (&AuthCanCmd{Roles: []string{"bue_buchen"}}).Run(ctx); err != nil {
+		t.Fatalf("auth can: %v", err)
+	}
+	var payload authCanPayload
+	if err := json.Unmarshal(out.Bytes(), &payload); err != nil || !payload.Allowed {
+		t.Errorf("auth can = %s (%v), want allowed from the refreshed token", out, err)
+	}
+	if got := realm.TokenHits.Load(); got != 1 {
+		t.Errorf("token endpoint hit %d times, want 1", got)
+	}
+}
@@ -15,21 +15,29 @@ func New() *CLI {
 type CLI struct {
 	Globals Globals `kong:"embed"`
 
-	Auth AuthCmd `kong:"cmd,help='Authentication and token management.'"`
+	Auth   AuthCmd   `kong:"cmd,help='Authentication and token management.'"`
+	Schema SchemaCmd `kong:"cmd,help='Print JSON Schemas of command outputs.'"`
 }
 
 type Globals struct {
-	Config  string           `help:"Config file path." env:"BAHN_CONFIG"`
-	Human   bool             `help:"Human-readable output." env:"BAHN_HUMAN"`
-	Quiet   bool             `short:"q" help:"Suppress stderr diagnostics." env:"BAHN_QUIET"`
-	Verbose bool             `short:"v" help:"Extra detail in stderr." env:"BAHN_VERBOSE"`
-	APIKey  string           `help:"RIS API key." env:"BAHN_API_KEY"`
-	Version kong.VersionFlag `help:"Print version."`
+	Config   string           `help:"Config file path." env:"BAHN_CONFIG"`
+	Format   string           `help:"Output format: json, human, ndjson, csv or tsv (default: $BAHN_FORMAT, then $BAHN_HUMAN, then [output] format)." placeholder:"FORMAT"`
+	Human    bool             `help:"Human-readable output (same as --format human)."`
+	Fields   []string         `help:"Only output these JSON fields; dotted paths select nested keys." placeholder:"A,B.C"`
+	Envelope bool             `help:"Wrap JSON results as {data, meta} with command, version, timing and warnings." env:"BAHN_ENVELOPE"`
+	Quiet    bool             `short:"q" help:"Suppress stderr diagnostics." env:"BAHN_QUIET"`
+	Verbose  bool             `short:"v" help:"Extra detail in stderr." env:"BAHN_VERBOSE"`
+	APIKey   string           `help:"RIS API key." env:"BAHN_API_KEY"`
+	Account  string           `help:"Auth account name." env:"BAHN_ACCOUNT"`
+	Version  kong.VersionFlag `help:"Print version."`
 }
 
+// Settings maps the flags to app.Settings. Format stays empty unless
+// --format or --human is given, so NewContext can fall back to
+// BAHN_FORMAT, BAHN_HUMAN and the config file.
 func (g Globals) Settings() app.Settings {
-	format := output.FormatJSON
-	if g.Human {
+	format := output.Format(g.Format)
+	if format == "" && g.Human {
 		format = output.FormatHuman
 	}
 	return app.Settings{
@@ -38,6 +46,10 @@ func (g Globals) Settings() app.Settings {
 		Quiet:      g.Quiet,
 		Verbose:    g.Verbose,
 		APIKey:     g.APIKey,
+		Account:    g.Account,
+		Fields:     g.Fields,
+		Envelope:   g.Envelope,
+		Version:    Version,
 	}
 }
 
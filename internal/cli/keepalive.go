@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/havocked/bahn-cli/internal/app"
+	"github.com/havocked/bahn-cli/internal/auth"
+)
+
+// --- auth keep-alive ---
+
+type AuthKeepAliveCmd struct {
+	cookieFlags
+	Lead       time.Duration `help:"Refresh this long before the token expires." default:"60s"`
+	MinBackoff time.Duration `help:"First retry delay after a failed refresh." default:"5s"`
+	MaxBackoff time.Duration `help:"Longest retry delay after repeated failures." default:"2m"`
+}
+
+// keepAliveEvent is one NDJSON line on stderr.
+type keepAliveEvent struct {
+	Time      string `json:"time"`
+	Event     string `json:"event"`
+	Attempt   int    `json:"attempt,omitempty"`
+	OK        bool   `json:"ok"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Failures  int    `json:"failures,omitempty"`
+	NextIn    string `json:"nextIn,omitempty"`
+}
+
+func (cmd *AuthKeepAliveCmd) Run(ctx *app.Context) error {
+	src, err := cmd.source()
+	if err != nil {
+		return err
+	}
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	enc := json.NewEncoder(ctx.Output.Err)
+	logEvent := func(ev keepAliveEvent) {
+		ev.Time = time.Now().UTC().Format(time.RFC3339)
+		_ = enc.Encode(ev)
+	}
+
+	logEvent(keepAliveEvent{Event: "start", OK: true})
+	err = auth.KeepAlive(sigCtx, auth.KeepAliveOptions{
+		Lead:       cmd.Lead,
+		MinBackoff: cmd.MinBackoff,
+		MaxBackoff: cmd.MaxBackoff,
+		Source:     src,
+		OnCycle: func(c auth.KeepAliveCycle) {
+			ev := keepAliveEvent{
+				Event:    "refresh",
+				Attempt:  c.Attempt,
+				OK:       c.Err == nil,
+				Failures: c.Failures,
+			}
+			if c.Next > 0 {
+				ev.NextIn = c.Next.Round(time.Second).String()
+			}
+			if c.Tokens != nil {
+				ev.ExpiresAt = c.Tokens.ExpiresAt.Format(time.RFC3339)
+			}
+			if c.Err != nil {
+				ev.Error = c.Err.Error()
+			}
+			logEvent(ev)
+		},
+	})
+	if err != nil {
+		logEvent(keepAliveEvent{Event: "stop", Error: err.Error()})
+		return err
+	}
+	logEvent(keepAliveEvent{Event: "stop", OK: true})
+	return nil
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/havocked/bahn-cli/internal/app"
+	"github.com/havocked/bahn-cli/internal/auth"
+	"github.com/havocked/bahn-cli/internal/output"
+	"github.com/havocked/bahn-cli/internal/schema"
+)
+
+// payloadTypes maps each command to the type it emits on stdout. Add new
+// commands here and run `make schemas`.
+var payloadTypes = map[string]reflect.Type{
+	"auth status":          reflect.TypeFor[[]authStatusPayload](),
+	"auth token":           reflect.TypeFor[tokenPayload](),
+	"auth login":           reflect.TypeFor[tokenPayload](),
+	"auth refresh":         reflect.TypeFor[tokenPayload](),
+	"auth clear":           reflect.TypeFor[okPayload](),
+	"auth logout":          reflect.TypeFor[logoutPayload](),
+	"auth accounts list":   reflect.TypeFor[[]accountPayload](),
+	"auth accounts use":    reflect.TypeFor[accountChangePayload](),
+	"auth accounts remove": reflect.TypeFor[accountChangePayload](),
+	"auth can":             reflect.TypeFor[authCanPayload](),
+	"auth doctor":          reflect.TypeFor[[]auth.Check](),
+	"auth print-token":     reflect.TypeFor[printTokenPayload](),
+	"error":                reflect.TypeFor[output.ErrorPayload](),
+}
+
+// payloadDescriptions notes where a schema covers only part of a
+// command's output.
+var payloadDescriptions = map[string]string{
+	"auth print-token": "Output of --as json only; raw, header and env print plain text.",
+}
+
+// ndjsonNote describes list payloads, which ndjson prints item by item.
+const ndjsonNote = "With --format ndjson each item is printed as its own line."
+
+// payloadSchema returns the schema of the named command's payload.
+func payloadSchema(name string) schema.Schema {
+	t := payloadTypes[name]
+	s := schema.For(t)
+	var desc []string
+	if d, ok := payloadDescriptions[name]; ok {
+		desc = append(desc, d)
+	}
+	if t.Kind() == reflect.Slice {
+		desc = append(desc, ndjsonNote)
+	}
+	if len(desc) > 0 {
+		s["description"] = strings.Join(desc, " ")
+	}
+	return s
+}
+
+// envelopeSchema describes --envelope output. data is a command's
+// payload, or one item of a list payload in ndjson mode. Errors are not
+// wrapped; they carry meta inline.
+func envelopeSchema() schema.Schema {
+	var refs []schema.Schema
+	for _, name := range schemaNames() {
+		if name == "error" {
+			continue
+		}
+		ref := "#/$defs/" + url.PathEscape(name)
+		refs = append(refs, schema.Schema{"$ref": ref})
+		if payloadTypes[name].Kind() == reflect.Slice {
+			refs = append(refs, schema.Schema{"$ref": ref + "/items"})
+		}
+	}
+	return schema.Schema{
+		"description": "With --envelope every result is wrapped as {data, meta}; in ndjson mode every line is.",
+		"type":        "object",
+		"properties": schema.Schema{
+			"data": schema.Schema{"anyOf": refs},
+			"meta": schema.For(reflect.TypeFor[output.Meta]()),
+		},
+		"required":             []string{"data", "meta"},
+		"additionalProperties": false,
+	}
+}
+
+// --- schema ---
+
+type SchemaCmd struct {
+	Command []string `arg:"" optional:"" help:"Command path, e.g. auth status. 'error' is the error object."`
+	All     bool     `help:"Print one bundle with every command's schema, and the --envelope wrapper, under $defs."`
+}
+
+// Run prints the schema as is: schemas are documents, so --fields and
+// --envelope don't apply.
+func (cmd *SchemaCmd) Run(ctx *app.Context) error {
+	if cmd.All {
+		return writeSchema(ctx, schemaBundle())
+	}
+	name := strings.Join(cmd.Command, " ")
+	if _, ok := payloadTypes[name]; !ok {
+		return app.Errorf(app.CodeInvalidInput, "no schema for %q (known: %s)", name, strings.Join(schemaNames(), ", "))
+	}
+	return writeSchema(ctx, schema.Document(fmt.Sprintf("bahn %s", name), payloadSchema(name)))
+}
+
+func writeSchema(ctx *app.Context, s schema.Schema) error {
+	data, err := marshalSchema(s)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.Output.Out.Write(data)
+	return err
+}
+
+// marshalSchema is the exact text `bahn schema` prints, which is what
+// schemas/bahn.schema.json holds.
+func marshalSchema(s schema.Schema) ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func schemaBundle() schema.Schema {
+	defs := schema.Schema{}
+	for name := range payloadTypes {
+		defs[name] = payloadSchema(name)
+	}
+	defs["envelope"] = envelopeSchema()
+	// No version: the bundle only changes when a payload does.
+	return schema.Document("bahn-cli command outputs", schema.Schema{"$defs": defs})
+}
+
+func schemaNames() []string {
+	names := make([]string, 0, len(payloadTypes))
+	for name := range payloadTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/havocked/bahn-cli/internal/schema"
+)
+
+func TestSchemaBundleUpToDate(t *testing.T) {
+	want, err := os.ReadFile("../../schemas/bahn.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := marshalSchema(schemaBundle())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("schemas/bahn.schema.json is stale: run make schemas")
+	}
+}
+
+func TestEnvelopeSchemaRefsResolve(t *testing.T) {
+	defs := schemaBundle()["$defs"].(schema.Schema)
+	data := envelopeSchema()["properties"].(schema.Schema)["data"].(schema.Schema)
+	for _, ref := range data["anyOf"].([]schema.Schema) {
+		target, _ := ref["$ref"].(string)
+		path, ok := strings.CutPrefix(target, "#/$defs/")
+		if !ok {
+			t.Errorf("$ref %q is not under $defs", target)
+			continue
+		}
+		name, sub, _ := strings.Cut(path, "/")
+		name, err := url.PathUnescape(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		def, ok := defs[name].(schema.Schema)
+		if !ok {
+			t.Errorf("$ref %q: no $def %q", target, name)
+			continue
+		}
+		if sub != "" && def[sub] == nil {
+			t.Errorf("$ref %q: $def %q has no %q", target, name, sub)
+		}
+	}
+}
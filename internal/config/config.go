@@ -12,6 +12,7 @@ const DefaultConfigFile = "config.toml"
 
 type Config struct {
 	API    APIConfig    `toml:"api"`
+	Auth   AuthConfig   `toml:"auth"`
 	Output OutputConfig `toml:"output"`
 	Watch  WatchConfig  `toml:"watch"`
 }
@@ -21,6 +22,26 @@ type APIConfig struct {
 	DefaultStation string `toml:"default_station"`
 }
 
+type AuthConfig struct {
+	Store   string `toml:"store"`    // file (default) | keyring
+	KeyFile string `toml:"key_file"` // encrypts the token file when set
+	Verify  bool   `toml:"verify"`   // check JWT signatures against the JWKS
+	JWKSURL string `toml:"jwks_url"` // defaults to the provider's jwks_uri
+
+	// Identity provider. Endpoints are discovered from the issuer's
+	// /.well-known/openid-configuration; empty values keep the bahn.de
+	// defaults.
+	Issuer      string `toml:"issuer"`
+	ClientID    string `toml:"client_id"`
+	Scopes      string `toml:"scopes"`
+	RedirectURI string `toml:"redirect_uri"`
+
+	// Where silent refresh reads Keycloak session cookies from.
+	Browser     string `toml:"browser"`      // empty: all browsers
+	Profile     string `toml:"profile"`      // browser profile name or path
+	CookiesFile string `toml:"cookies_file"` // Netscape/curl cookie jar
+}
+
 type OutputConfig struct {
 	Format string `toml:"format"`
 }
@@ -99,6 +120,9 @@ func Default() *Config {
 		API: APIConfig{
 			DefaultStation: "Leipzig Hbf",
 		},
+		Auth: AuthConfig{
+			Store: "file",
+		},
 		Output: OutputConfig{
 			Format: "json",
 		},
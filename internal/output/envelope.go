@@ -0,0 +1,83 @@
+package output
+
+import (
+	"sync"
+	"time"
+)
+
+// Meta describes a result in --envelope mode.
+type Meta struct {
+	Command     string   `json:"command"`
+	Version     string   `json:"version"`
+	GeneratedAt string   `json:"generatedAt"`
+	Source      string   `json:"source"`
+	Cached      bool     `json:"cached"`
+	DurationMs  int64    `json:"durationMs"`
+	Warnings    []string `json:"warnings"`
+}
+
+type envelope struct {
+	Data any   `json:"data"`
+	Meta *Meta `json:"meta"`
+}
+
+// envelopeState is the metadata collected while a command runs.
+type envelopeState struct {
+	mu       sync.Mutex
+	start    time.Time
+	meta     Meta
+	warnings []string
+}
+
+// SetCommand records the command path, e.g. "auth status".
+func (w *Writer) SetCommand(command string) {
+	if w.env == nil {
+		return
+	}
+	w.env.mu.Lock()
+	defer w.env.mu.Unlock()
+	w.env.meta.Command = command
+}
+
+// SetSource records where the data came from and whether it was served
+// from local state rather than fetched, e.g. ("token-store", true).
+func (w *Writer) SetSource(source string, cached bool) {
+	if w.env == nil {
+		return
+	}
+	w.env.mu.Lock()
+	defer w.env.mu.Unlock()
+	w.env.meta.Source = source
+	w.env.meta.Cached = cached
+}
+
+func (w *Writer) warn(msg string) {
+	if w.env == nil {
+		return
+	}
+	w.env.mu.Lock()
+	defer w.env.mu.Unlock()
+	w.env.warnings = append(w.env.warnings, msg)
+}
+
+// wrap returns value inside {data, meta} in envelope mode.
+func (w *Writer) wrap(value any) any {
+	if w.env == nil {
+		return value
+	}
+	return envelope{Data: value, Meta: w.meta()}
+}
+
+// meta snapshots the envelope metadata; nil outside envelope mode.
+func (w *Writer) meta() *Meta {
+	if w.env == nil {
+		return nil
+	}
+	w.env.mu.Lock()
+	defer w.env.mu.Unlock()
+	meta := w.env.meta
+	meta.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
+	meta.DurationMs = time.Since(w.env.start).Milliseconds()
+	meta.Warnings = append([]string{}, w.env.warnings...)
+	return &meta
+}
@@ -0,0 +1,154 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestErrorJSONEnvelope(t *testing.T) {
+	var out bytes.Buffer
+	w := New(Options{Format: FormatJSON, Out: &out, Err: &bytes.Buffer{}, Envelope: true, Version: "1.2.3"})
+	w.SetCommand("auth status")
+	w.Infof("stored cookies expired")
+	if err := w.ErrorJSON("session_expired", "session expired", "run `bahn auth login`"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Error string `json:"error"`
+		Meta  *Meta  `json:"meta"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Error != "session_expired" || got.Meta == nil {
+		t.Fatalf("got %s", out.String())
+	}
+	if got.Meta.Command != "auth status" || got.Meta.Version != "1.2.3" ||
+		len(got.Meta.Warnings) != 1 || got.Meta.Warnings[0] != "stored cookies expired" {
+		t.Errorf("meta = %+v", *got.Meta)
+	}
+}
+
+func TestErrorJSONPlain(t *testing.T) {
+	var out bytes.Buffer
+	w := New(Options{Format: FormatJSON, Out: &out})
+	if err := w.ErrorJSON("network", "dial failed", ""); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["meta"]; ok || len(got) != 2 {
+		t.Errorf("got %v, want only error and message", got)
+	}
+}
+
+func TestEnvelopeJSON(t *testing.T) {
+	var out, errOut bytes.Buffer
+	w := New(Options{Format: FormatJSON, Out: &out, Err: &errOut, Envelope: true, Version: "1.2.3"})
+	w.SetCommand("auth status")
+	w.SetSource("keycloak", false)
+	w.SetSource("token-store", true)
+	w.Infof("refresh failed: %s", "offline")
+	if err := w.Emit(map[string]string{"status": "ok"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Data map[string]string `json:"data"`
+		Meta Meta              `json:"meta"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Data["status"] != "ok" {
+		t.Errorf("data = %v", got.Data)
+	}
+	m := got.Meta
+	if m.Command != "auth status" || m.Version != "1.2.3" || m.Source != "token-store" || !m.Cached {
+		t.Errorf("meta = %+v", m)
+	}
+	if m.GeneratedAt == "" || m.DurationMs < 0 {
+		t.Errorf("meta timing = %q, %d", m.GeneratedAt, m.DurationMs)
+	}
+	if len(m.Warnings) != 1 || m.Warnings[0] != "refresh failed: offline" {
+		t.Errorf("warnings = %q", m.Warnings)
+	}
+	if errOut.String() != "refresh failed: offline\n" {
+		t.Errorf("stderr = %q", errOut.String())
+	}
+}
+
+func TestEnvelopeNDJSON(t *testing.T) {
+	var out bytes.Buffer
+	w := New(Options{Format: FormatNDJSON, Out: &out, Err: &bytes.Buffer{}, Envelope: true})
+	w.SetCommand("auth accounts list")
+	s := w.Stream()
+	for _, name := range []string{"default", "work"} {
+		if err := s.Send(map[string]string{"name": name}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := bytes.Split(bytes.TrimSuffix(out.Bytes(), []byte("\n")), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), out.String())
+	}
+	for i, want := range []string{"default", "work"} {
+		var got struct {
+			Data map[string]string `json:"data"`
+			Meta *Meta             `json:"meta"`
+		}
+		if err := json.Unmarshal(lines[i], &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Data["name"] != want || got.Meta == nil || got.Meta.Command != "auth accounts list" {
+			t.Errorf("line %d = %s", i+1, lines[i])
+		}
+	}
+}
+
+func TestEnvelopeWarningsWhenQuiet(t *testing.T) {
+	var out, errOut bytes.Buffer
+	w := New(Options{Format: FormatJSON, Out: &out, Err: &errOut, Quiet: true, Envelope: true})
+	w.Infof("stored cookies expired")
+	if err := w.JSON(map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Meta Meta `json:"meta"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Meta.Warnings) != 1 || got.Meta.Warnings[0] != "stored cookies expired" {
+		t.Errorf("warnings = %q, want the quiet diagnostic", got.Meta.Warnings)
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("stderr = %q, want nothing under --quiet", errOut.String())
+	}
+}
+
+func TestNoEnvelope(t *testing.T) {
+	var out bytes.Buffer
+	w := New(Options{Format: FormatJSON, Out: &out, Err: &bytes.Buffer{}})
+	w.SetCommand("auth status")
+	w.SetSource("keycloak", false)
+	w.Infof("a warning")
+	if err := w.JSON(map[string]string{"status": "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got["status"] != "ok" {
+		t.Errorf("got %v, want the bare result", got)
+	}
+}
@@ -0,0 +1,110 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// parseFields splits --fields entries like "a", "b.c" into paths.
+// Entries may themselves be comma-separated.
+func parseFields(fields []string) [][]string {
+	var paths [][]string
+	for _, f := range fields {
+		for _, part := range strings.Split(f, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				paths = append(paths, strings.Split(part, "."))
+			}
+		}
+	}
+	return paths
+}
+
+// project reduces value to the selected paths. Arrays are projected
+// element by element, so the same fields work for single results and
+// lists. Keys come out in the order they were asked for; missing keys
+// are left out.
+func project(value any, paths [][]string) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return projectValue(generic, paths), nil
+}
+
+func projectValue(v any, paths [][]string) any {
+	switch v := v.(type) {
+	case []any:
+		out := make([]any, len(v))
+		for i, elem := range v {
+			out[i] = projectValue(elem, paths)
+		}
+		return out
+	case map[string]any:
+		var keys []string
+		sub := map[string][][]string{}
+		whole := map[string]bool{}
+		for _, p := range paths {
+			key := p[0]
+			if _, seen := sub[key]; !seen && !whole[key] {
+				keys = append(keys, key)
+			}
+			if len(p) == 1 {
+				whole[key] = true
+			} else {
+				sub[key] = append(sub[key], p[1:])
+			}
+		}
+		out := orderedObject{}
+		for _, key := range keys {
+			val, ok := v[key]
+			if !ok {
+				continue
+			}
+			if !whole[key] {
+				val = projectValue(val, sub[key])
+			}
+			out = append(out, field{key, val})
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+type field struct {
+	key   string
+	value any
+}
+
+// orderedObject is a JSON object that keeps its key order.
+type orderedObject []field
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,98 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+		value  any
+		want   string
+	}{
+		{"single object", []string{"id", "price"}, testTrips[1],
+			`{"id":"t2","price":19}`},
+		{"request order", []string{"price", "id"}, testTrips[1],
+			`{"price":19,"id":"t2"}`},
+		{"comma-separated", []string{"price,id"}, testTrips[1],
+			`{"price":19,"id":"t2"}`},
+		{"array per element", []string{"id"}, testTrips,
+			`[{"id":"t1"},{"id":"t2"}]`},
+		{"nested path", []string{"card.type"}, testTrips[0],
+			`{"card":{"type":"BC50"}}`},
+		{"path through an array", []string{"legs.origin"}, testTrips[0],
+			`{"legs":[{"origin":"Berlin Hbf"},{"origin":"Leipzig Hbf"}]}`},
+		// A whole key wins over its sub-paths, whichever comes first.
+		{"whole key and sub-path", []string{"card.type", "card"}, testTrips[0],
+			`{"card":{"class":2,"type":"BC50"}}`},
+		{"sub-path after whole key", []string{"card", "card.type"}, testTrips[0],
+			`{"card":{"class":2,"type":"BC50"}}`},
+		{"missing keys", []string{"id", "nope", "card.nope"}, testTrips[1],
+			`{"id":"t2"}`},
+		{"missing nested key", []string{"card.nope"}, testTrips[0],
+			`{"card":{}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := New(Options{Format: FormatJSON, Out: &out, Fields: tt.fields})
+			if err := w.JSON(tt.value); err != nil {
+				t.Fatal(err)
+			}
+			var got bytes.Buffer
+			if err := json.Compact(&got, out.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("got %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldsNDJSON(t *testing.T) {
+	var out bytes.Buffer
+	w := New(Options{Format: FormatNDJSON, Out: &out, Fields: []string{"price", "id"}})
+	if err := w.Emit(testTrips, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"price":49.9,"id":"t1"}` + "\n" + `{"price":19,"id":"t2"}` + "\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestFieldsEnvelope(t *testing.T) {
+	var out bytes.Buffer
+	w := New(Options{Format: FormatJSON, Out: &out, Err: &bytes.Buffer{}, Fields: []string{"id", "command"}, Envelope: true, Version: "1.2.3"})
+	w.SetCommand("trips list")
+	w.Infof("one warning")
+	if err := w.JSON(testTrips); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Data []map[string]any `json:"data"`
+		Meta *Meta            `json:"meta"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Data) != 2 || len(got.Data[0]) != 1 || got.Data[0]["id"] != "t1" {
+		t.Errorf("data = %v, want only ids", got.Data)
+	}
+	// Fields select inside data; meta keeps every key.
+	if got.Meta == nil || got.Meta.Command != "trips list" || got.Meta.Version != "1.2.3" || got.Meta.Source != "local" {
+		t.Fatalf("meta = %+v", got.Meta)
+	}
+	if !strings.Contains(out.String(), `"generatedAt"`) || !strings.Contains(out.String(), `"durationMs"`) {
+		t.Errorf("meta lost keys: %s", out.String())
+	}
+	if len(got.Meta.Warnings) != 1 || got.Meta.Warnings[0] != "one warning" {
+		t.Errorf("warnings = %v", got.Meta.Warnings)
+	}
+}
@@ -5,22 +5,41 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"reflect"
+	"strings"
+	"time"
 )
 
 // Format controls how output is rendered.
 type Format string
 
 const (
-	FormatJSON  Format = "json"
-	FormatHuman Format = "human"
+	FormatJSON   Format = "json"
+	FormatHuman  Format = "human"
+	FormatNDJSON Format = "ndjson"
+	FormatCSV    Format = "csv"
+	FormatTSV    Format = "tsv"
 )
 
+// ParseFormat validates a --format value.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatJSON, FormatHuman, FormatNDJSON, FormatCSV, FormatTSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (want json, human, ndjson, csv or tsv)", s)
+}
+
 // Writer handles structured output to stdout (data) and stderr (diagnostics).
 type Writer struct {
 	Format Format
 	Out    io.Writer
 	Err    io.Writer
 	Quiet  bool
+	// Fields restricts JSON output to these dotted paths (--fields).
+	Fields [][]string
+
+	env *envelopeState // nil unless --envelope
 }
 
 // Options for creating a Writer.
@@ -29,6 +48,10 @@ type Options struct {
 	Out    io.Writer
 	Err    io.Writer
 	Quiet  bool
+	Fields []string
+	// Envelope wraps JSON results as {data, meta}; Version goes into meta.
+	Envelope bool
+	Version  string
 }
 
 // New creates an output Writer.
@@ -45,17 +68,57 @@ func New(opts Options) *Writer {
 	if errOut == nil {
 		errOut = os.Stderr
 	}
-	return &Writer{
+	w := &Writer{
 		Format: format,
 		Out:    out,
 		Err:    errOut,
 		Quiet:  opts.Quiet,
+		Fields: parseFields(opts.Fields),
 	}
+	if opts.Envelope {
+		w.env = &envelopeState{
+			start: time.Now(),
+			meta:  Meta{Version: opts.Version, Source: "local"},
+		}
+	}
+	return w
 }
 
 // JSON writes a value as JSON to stdout. This is the primary data channel.
+// With --envelope the value becomes the envelope's data.
 func (w *Writer) JSON(value any) error {
-	data, err := json.MarshalIndent(value, "", "  ")
+	value, err := w.project(value)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(w.wrap(value), true)
+}
+
+// Line writes a value as one compact JSON line to stdout. With
+// --envelope every line is its own envelope.
+func (w *Writer) Line(value any) error {
+	value, err := w.project(value)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(w.wrap(value), false)
+}
+
+func (w *Writer) project(value any) (any, error) {
+	if len(w.Fields) == 0 {
+		return value, nil
+	}
+	return project(value, w.Fields)
+}
+
+func (w *Writer) writeJSON(value any, indent bool) error {
+	var data []byte
+	var err error
+	if indent {
+		data, err = json.MarshalIndent(value, "", "  ")
+	} else {
+		data, err = json.Marshal(value)
+	}
 	if err != nil {
 		return err
 	}
@@ -64,26 +127,122 @@ func (w *Writer) JSON(value any) error {
 }
 
 // Emit writes structured output. In JSON mode, emits the value.
-// In human mode, writes humanLines to stdout.
+// In NDJSON mode, emits one line per element of a slice or array, or one
+// line for any other value. In csv and tsv mode, flattens value into rows.
+// In human mode, writes humanLines to stdout, or renders value from its
+// `human` tags when humanLines is nil.
 func (w *Writer) Emit(value any, humanLines []string) error {
 	switch w.Format {
 	case FormatHuman:
-		if w.Quiet {
-			return nil
+		if humanLines == nil {
+			return w.render(value)
 		}
-		for _, line := range humanLines {
-			if _, err := fmt.Fprintln(w.Out, line); err != nil {
+		return w.human(humanLines)
+	case FormatNDJSON:
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return w.Line(value)
+		}
+		for i := range rv.Len() {
+			if err := w.Line(rv.Index(i).Interface()); err != nil {
 				return err
 			}
 		}
 		return nil
+	case FormatCSV, FormatTSV:
+		return w.writeTable(value)
 	default:
 		return w.JSON(value)
 	}
 }
 
-// Infof writes a diagnostic message to stderr.
+func (w *Writer) human(lines []string) error {
+	if w.Quiet {
+		return nil
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w.Out, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stream emits a list item by item. In NDJSON and human mode each item
+// is written as soon as it is sent; in JSON, csv and tsv mode the items
+// are collected and written by Close. Items sent without human lines are
+// rendered as one table by Close, since column widths need every row.
+type Stream struct {
+	w     *Writer
+	items []any
+	rows  []any
+	sent  int
+}
+
+// Stream starts a list output. Callers must Close it.
+func (w *Writer) Stream() *Stream {
+	return &Stream{w: w, items: []any{}}
+}
+
+// Send emits one item, with humanLines as its human rendering.
+func (s *Stream) Send(value any, humanLines []string) error {
+	defer func() { s.sent++ }()
+	switch s.w.Format {
+	case FormatHuman:
+		if humanLines == nil {
+			s.rows = append(s.rows, value)
+			return nil
+		}
+		return s.w.human(humanLines)
+	case FormatNDJSON:
+		return s.w.Line(value)
+	default:
+		s.items = append(s.items, value)
+		return nil
+	}
+}
+
+// Close finishes the list. emptyLines is the human output when nothing
+// was sent.
+func (s *Stream) Close(emptyLines []string) error {
+	switch s.w.Format {
+	case FormatHuman:
+		if s.sent == 0 {
+			return s.w.human(emptyLines)
+		}
+		if len(s.rows) == 0 {
+			return nil
+		}
+		t := reflect.TypeOf(s.rows[0])
+		for _, r := range s.rows {
+			if reflect.TypeOf(r) != t || t == nil {
+				// Mixed items can't share a table; render them one by one.
+				for _, r := range s.rows {
+					if err := s.w.render(r); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		}
+		rows := reflect.MakeSlice(reflect.SliceOf(t), 0, len(s.rows))
+		for _, r := range s.rows {
+			rows = reflect.Append(rows, reflect.ValueOf(r))
+		}
+		return s.w.render(rows.Interface())
+	case FormatNDJSON:
+		return nil
+	case FormatCSV, FormatTSV:
+		return s.w.writeTable(s.items)
+	default:
+		return s.w.JSON(s.items)
+	}
+}
+
+// Infof writes a diagnostic message to stderr. With --envelope it is
+// also collected into meta.warnings, even when --quiet.
 func (w *Writer) Infof(format string, args ...any) {
+	w.warn(fmt.Sprintf(format, args...))
 	if w.Quiet {
 		return
 	}
@@ -95,14 +254,24 @@ func (w *Writer) Errorf(format string, args ...any) {
 	_, _ = fmt.Fprintf(w.Err, "error: "+format+"\n", args...)
 }
 
+// ErrorPayload is the structured error written by ErrorJSON.
+type ErrorPayload struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+	Action  string `json:"action,omitempty"`
+	// Meta is set with --envelope.
+	Meta *Meta `json:"meta,omitempty"`
+}
+
 // ErrorJSON writes a structured error to stdout (for agent consumption).
+// With --envelope it carries the same meta as a result would.
 func (w *Writer) ErrorJSON(errType string, message string, action string) error {
-	payload := map[string]string{
-		"error":   errType,
-		"message": message,
-	}
-	if action != "" {
-		payload["action"] = action
+	payload := ErrorPayload{
+		Error:   errType,
+		Message: message,
+		Action:  action,
+		Meta:    w.meta(),
 	}
-	return w.JSON(payload)
+	// Errors keep their shape whatever --fields selects.
+	return w.writeJSON(payload, w.Format != FormatNDJSON)
 }
@@ -0,0 +1,138 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type testItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+var testItems = []testItem{{1, "Berlin Hbf"}, {2, "Leipzig Hbf"}, {3, "München Hbf"}}
+
+// ndjsonLines splits out into lines and checks each is one compact
+// JSON value.
+func ndjsonLines(t *testing.T, out *bytes.Buffer) []string {
+	t.Helper()
+	if out.Len() == 0 {
+		return nil
+	}
+	if !strings.HasSuffix(out.String(), "\n") {
+		t.Fatalf("output %q does not end in a newline", out.String())
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	for _, line := range lines {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(line)); err != nil {
+			t.Fatalf("line %q is not JSON: %v", line, err)
+		}
+		if compact.String() != line {
+			t.Errorf("line %q is not compact", line)
+		}
+	}
+	return lines
+}
+
+func TestEmitNDJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  []string
+	}{
+		{"slice", testItems, []string{
+			`{"id":1,"name":"Berlin Hbf"}`,
+			`{"id":2,"name":"Leipzig Hbf"}`,
+			`{"id":3,"name":"München Hbf"}`,
+		}},
+		{"array", [2]testItem{testItems[0], testItems[1]}, []string{
+			`{"id":1,"name":"Berlin Hbf"}`,
+			`{"id":2,"name":"Leipzig Hbf"}`,
+		}},
+		{"single", testItems[0], []string{`{"id":1,"name":"Berlin Hbf"}`}},
+		{"empty", []testItem{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := New(Options{Format: FormatNDJSON, Out: &out})
+			if err := w.Emit(tt.value, []string{"human only"}); err != nil {
+				t.Fatal(err)
+			}
+			got := ndjsonLines(t, &out)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamNDJSONWritesOnSend(t *testing.T) {
+	var out bytes.Buffer
+	w := New(Options{Format: FormatNDJSON, Out: &out})
+	s := w.Stream()
+	for i, item := range testItems {
+		if err := s.Send(item, nil); err != nil {
+			t.Fatal(err)
+		}
+		lines := ndjsonLines(t, &out)
+		if len(lines) != i+1 {
+			t.Fatalf("after Send %d: %d lines, want %d", i+1, len(lines), i+1)
+		}
+		var got testItem
+		if err := json.Unmarshal([]byte(lines[i]), &got); err != nil || got != item {
+			t.Errorf("line %d = %s, want %+v", i+1, lines[i], item)
+		}
+	}
+	before := out.Len()
+	if err := s.Close([]string{"nothing"}); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != before {
+		t.Errorf("Close wrote %q", out.String()[before:])
+	}
+}
+
+func TestStreamJSONWritesOnClose(t *testing.T) {
+	var out bytes.Buffer
+	w := New(Options{Format: FormatJSON, Out: &out})
+	s := w.Stream()
+	for _, item := range testItems {
+		if err := s.Send(item, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out.Len() != 0 {
+		t.Fatalf("JSON stream wrote before Close: %q", out.String())
+	}
+	if err := s.Close(nil); err != nil {
+		t.Fatal(err)
+	}
+	var got []testItem
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil || len(got) != len(testItems) {
+		t.Errorf("Close wrote %s (%v), want the items as one array", out.String(), err)
+	}
+
+	// An empty stream is an empty array, not null.
+	out.Reset()
+	if err := w.Stream().Close(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "[]" {
+		t.Errorf("empty stream = %q, want []", got)
+	}
+}
+
+func TestErrorJSONNDJSON(t *testing.T) {
+	var out bytes.Buffer
+	w := New(Options{Format: FormatNDJSON, Out: &out})
+	if err := w.ErrorJSON("network", "dial failed", "retry"); err != nil {
+		t.Fatal(err)
+	}
+	if lines := ndjsonLines(t, &out); len(lines) != 1 {
+		t.Errorf("error lines = %q, want one", lines)
+	}
+}
@@ -0,0 +1,284 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Human rendering is driven by `human` struct tags:
+//
+//	Name    string `human:"Account"`            // column or key label
+//	Expires string `human:"Expires,omitempty"`  // skip when empty
+//	Token   string `human:"-"`                  // never shown
+//
+// Untagged exported fields use the field name as label. Slices of structs
+// render as aligned tables, anything else as a key/value block. In a
+// table, an omitempty column is dropped when it is empty in every row.
+
+const (
+	defaultWidth = 80
+	minColumn    = 4
+	columnGap    = "  "
+)
+
+type humanField struct {
+	index     int
+	label     string
+	omitEmpty bool
+}
+
+func humanFields(t reflect.Type) []humanField {
+	var fields []humanField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		label, opts, _ := strings.Cut(f.Tag.Get("human"), ",")
+		if label == "-" {
+			continue
+		}
+		if label == "" {
+			label = f.Name
+		}
+		fields = append(fields, humanField{index: i, label: label, omitEmpty: opts == "omitempty"})
+	}
+	return fields
+}
+
+// render writes value in human form.
+func (w *Writer) render(value any) error {
+	return w.human(renderLines(value, w.width()))
+}
+
+func renderLines(value any, width int) []string {
+	v := indirect(reflect.ValueOf(value))
+	if !v.IsValid() {
+		return nil
+	}
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8 {
+		if isStructType(v.Type().Elem()) {
+			return table(v, width)
+		}
+		lines := make([]string, 0, v.Len())
+		for i := range v.Len() {
+			lines = append(lines, cell(v.Index(i)))
+		}
+		return lines
+	}
+	return keyValues(v, "", width)
+}
+
+// table renders a slice of structs as aligned columns. When the columns
+// don't fit width, the widest ones are narrowed and their cells cut.
+func table(rows reflect.Value, width int) []string {
+	fields := slices.DeleteFunc(humanFields(derefType(rows.Type().Elem())), func(f humanField) bool {
+		return f.omitEmpty && emptyColumn(rows, f.index)
+	})
+	header := make([]string, len(fields))
+	widths := make([]int, len(fields))
+	for i, f := range fields {
+		header[i] = f.label
+		widths[i] = utf8.RuneCountInString(f.label)
+	}
+	cells := make([][]string, rows.Len())
+	for r := range rows.Len() {
+		row := indirect(rows.Index(r))
+		cells[r] = make([]string, len(fields))
+		for i, f := range fields {
+			if !row.IsValid() {
+				continue
+			}
+			c := cell(row.Field(f.index))
+			cells[r][i] = c
+			widths[i] = max(widths[i], utf8.RuneCountInString(c))
+		}
+	}
+	fitColumns(widths, width-len(columnGap)*(len(fields)-1))
+
+	lines := []string{formatRow(header, widths)}
+	for _, row := range cells {
+		lines = append(lines, formatRow(row, widths))
+	}
+	return lines
+}
+
+func emptyColumn(rows reflect.Value, index int) bool {
+	for r := range rows.Len() {
+		if row := indirect(rows.Index(r)); row.IsValid() && !row.Field(index).IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// fitColumns shrinks the widest columns until their sum fits avail.
+func fitColumns(widths []int, avail int) {
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+	for total > avail {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minColumn {
+			return
+		}
+		widths[widest]--
+		total--
+	}
+}
+
+func formatRow(cells []string, widths []int) string {
+	parts := make([]string, len(cells))
+	for i, c := range cells {
+		c = truncate(c, widths[i])
+		if i < len(cells)-1 {
+			c += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(c))
+		}
+		parts[i] = c
+	}
+	return strings.TrimRight(strings.Join(parts, columnGap), " ")
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n-1]) + "…"
+}
+
+// keyValues renders a struct or map as "Label: value" lines, nesting
+// structs, maps and tables one level deeper.
+func keyValues(v reflect.Value, indent string, width int) []string {
+	type kv struct {
+		key string
+		val reflect.Value
+	}
+	var pairs []kv
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range humanFields(v.Type()) {
+			fv := v.Field(f.index)
+			if f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			pairs = append(pairs, kv{f.label, fv})
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(cell(a), cell(b)) })
+		for _, k := range keys {
+			pairs = append(pairs, kv{cell(k), v.MapIndex(k)})
+		}
+	default:
+		return []string{indent + cell(v)}
+	}
+
+	labelWidth := 0
+	for _, p := range pairs {
+		labelWidth = max(labelWidth, utf8.RuneCountInString(p.key))
+	}
+	var lines []string
+	for _, p := range pairs {
+		val := indirect(p.val)
+		if val.IsValid() && (isStructType(val.Type()) || val.Kind() == reflect.Map) {
+			lines = append(lines, indent+p.key+":")
+			lines = append(lines, keyValues(val, indent+"  ", width)...)
+			continue
+		}
+		if val.IsValid() && val.Kind() == reflect.Slice && isStructType(val.Type().Elem()) {
+			lines = append(lines, indent+p.key+":")
+			for _, line := range table(val, width-len(indent)-2) {
+				lines = append(lines, indent+"  "+line)
+			}
+			continue
+		}
+		pad := strings.Repeat(" ", labelWidth-utf8.RuneCountInString(p.key))
+		lines = append(lines, strings.TrimRight(fmt.Sprintf("%s%s:%s %s", indent, p.key, pad, cell(p.val)), " "))
+	}
+	return lines
+}
+
+// cell formats a scalar, list or time for display.
+func cell(v reflect.Value) string {
+	v = indirect(v)
+	if !v.IsValid() {
+		return ""
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Local().Format("2006-01-02 15:04")
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		if v.Bool() {
+			return "yes"
+		}
+		return "no"
+	case reflect.Slice, reflect.Array:
+		parts := make([]string, v.Len())
+		for i := range v.Len() {
+			parts[i] = cell(v.Index(i))
+		}
+		return strings.Join(parts, ", ")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func isStructType(t reflect.Type) bool {
+	t = derefType(t)
+	return t.Kind() == reflect.Struct && t != reflect.TypeFor[time.Time]()
+}
+
+// width is the terminal width of Out, else $COLUMNS, else 80.
+func (w *Writer) width() int {
+	if f, ok := w.Out.(*os.File); ok {
+		if cols, _, err := term.GetSize(int(f.Fd())); err == nil && cols > 0 {
+			return cols
+		}
+	}
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		return cols
+	}
+	return defaultWidth
+}
@@ -0,0 +1,124 @@
+package output
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type renderCard struct {
+	Type  string `human:"Type"`
+	Class int    `human:"Class"`
+}
+
+type renderLeg struct {
+	From string `human:"From"`
+	To   string `human:"To"`
+}
+
+type renderTrip struct {
+	ID       string            `human:"Trip"`
+	Origin   string            `human:"From"`
+	Booked   bool              `human:"Booked"`
+	Platform string            `human:"Platform,omitempty"`
+	Delay    int               `human:"Delay,omitempty"`
+	Token    string            `human:"-"`
+	Card     *renderCard       `human:"BahnCard,omitempty"`
+	Legs     []renderLeg       `human:"Legs,omitempty"`
+	Extra    map[string]string `human:"Extra,omitempty"`
+	Tags     []string          `human:"Tags,omitempty"`
+	Note     string
+}
+
+var renderTrips = []renderTrip{
+	{ID: "t1", Origin: "Berlin Hbf", Booked: true, Delay: 5, Token: "secret", Note: "window seat"},
+	{ID: "t2", Origin: "München Hbf (tief)", Token: "secret"},
+}
+
+func TestRenderGolden(t *testing.T) {
+	nested := renderTrip{
+		ID:     "t1",
+		Origin: "Berlin Hbf",
+		Booked: true,
+		Token:  "secret",
+		Card:   &renderCard{Type: "BC50", Class: 2},
+		Legs:   []renderLeg{{"Berlin Hbf", "Leipzig Hbf"}, {"Leipzig Hbf", "München Hbf"}},
+		Extra:  map[string]string{"seat": "42", "coach": "7"},
+		Tags:   []string{"work", "ice"},
+	}
+	long := []renderTrip{{
+		ID:     "t3",
+		Origin: "Frankfurt (Main) Flughafen Fernbahnhof",
+		Note:   "Reservierung erforderlich, Fahrradmitnahme nicht möglich",
+	}}
+
+	tests := []struct {
+		name  string
+		width int
+		value any
+	}{
+		// Platform is empty in every row and dropped; Delay is kept.
+		{"table", 80, renderTrips},
+		{"key_values", 80, renderTrips[0]},
+		{"nested", 80, &nested},
+		{"narrow", 40, long},
+		{"scalars", 80, []string{"Berlin Hbf", "Leipzig Hbf"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(renderLines(tt.value, tt.width), "\n") + "\n"
+			golden(t, filepath.Join("testdata", "render_"+tt.name+".golden"), []byte(got))
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"Leipzig", 7, "Leipzig"},
+		{"Leipzig Hbf", 7, "Leipzi…"},
+		{"München", 4, "Mün…"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.in, tt.n); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFitColumns(t *testing.T) {
+	widths := []int{10, 30, 6}
+	fitColumns(widths, 30)
+	if widths[0]+widths[1]+widths[2] != 30 || widths[2] != 6 {
+		t.Errorf("widths = %v, want the widest narrowed to a total of 30", widths)
+	}
+	// Columns never go below minColumn, even if that overflows.
+	widths = []int{5, 5}
+	fitColumns(widths, 2)
+	if widths[0] != minColumn || widths[1] != minColumn {
+		t.Errorf("widths = %v, want both at %d", widths, minColumn)
+	}
+}
+
+func TestStreamHumanMixedRows(t *testing.T) {
+	var out bytes.Buffer
+	w := New(Options{Format: FormatHuman, Out: &out})
+	s := w.Stream()
+	for _, row := range []any{renderTrips[0], renderLeg{From: "Berlin Hbf", To: "Leipzig Hbf"}, nil} {
+		if err := s.Send(row, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"t1", "Leipzig Hbf"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flattening rules for csv and tsv:
+//
+//   - a top-level array gives one row per element, anything else one row
+//   - columns come from the payload's Go type in field order, never from
+//     the data, so an omitempty field keeps its column when it is empty
+//   - nested structs become dotted columns: bahnCard.type
+//   - arrays of scalars are joined with "; " in one cell
+//   - arrays of objects and maps are written as compact JSON in one cell
+//   - null is an empty cell; numbers and booleans are written as in JSON
+//
+// With --fields the columns are the selected paths in the order given; a
+// path naming a struct expands to that struct's columns. Values without
+// a struct type (maps, any) fall back to columns in order of appearance.
+
+// writeTable writes value as csv or tsv, with a header row.
+func (w *Writer) writeTable(value any) error {
+	columns := tableColumns(value, w.Fields)
+	projected, err := w.project(value)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(projected)
+	if err != nil {
+		return err
+	}
+	root, err := decodeOrdered(json.NewDecoder(bytes.NewReader(data)))
+	if err != nil {
+		return err
+	}
+
+	items, ok := root.([]any)
+	if !ok {
+		items = []any{root}
+	}
+	if columns == nil {
+		columns = seenColumns(items)
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+	records := make([][]string, 0, len(items)+1)
+	records = append(records, columns)
+	for _, item := range items {
+		rec := make([]string, len(columns))
+		for i, c := range columns {
+			rec[i] = cellAt(item, strings.Split(c, "."))
+		}
+		records = append(records, rec)
+	}
+
+	if w.Format == FormatTSV {
+		return writeTSV(w, records)
+	}
+	cw := csv.NewWriter(w.Out)
+	if err := cw.WriteAll(records); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// tableColumns derives the columns from value's type, or from fields.
+// It returns nil when the row type is not a struct.
+func tableColumns(value any, fields [][]string) []string {
+	t := rowType(reflect.ValueOf(value))
+	if t == nil {
+		if len(fields) == 0 {
+			return nil
+		}
+		t = reflect.TypeFor[any]()
+	}
+	if len(fields) == 0 {
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		return structColumns(t, "")
+	}
+	var columns []string
+	for _, path := range fields {
+		name := strings.Join(path, ".")
+		if sub := pathType(t, path); sub != nil && sub.Kind() == reflect.Struct {
+			columns = append(columns, structColumns(sub, name)...)
+		} else {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
+
+// rowType is the type of one row: the element type of a list, else the
+// value's own type, with pointers removed. An interface element type
+// (e.g. a Stream's []any) is resolved from the first element.
+func rowType(v reflect.Value) reflect.Type {
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+	t := v.Type()
+	if (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8 {
+		t = t.Elem()
+		if t.Kind() == reflect.Interface {
+			if v.Len() == 0 {
+				return nil
+			}
+			elem := indirect(v.Index(0))
+			if !elem.IsValid() {
+				return nil
+			}
+			t = elem.Type()
+		}
+	}
+	return derefType(t)
+}
+
+// structColumns lists the leaf columns of struct t under prefix.
+func structColumns(t reflect.Type, prefix string) []string {
+	var columns []string
+	for _, f := range jsonFields(t) {
+		name := f.name
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if ft := derefType(f.typ); flattensAsStruct(ft) {
+			columns = append(columns, structColumns(ft, name)...)
+		} else {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
+
+// pathType resolves a dotted path against t, looking through pointers
+// and slices. It returns nil when the path leaves the known types.
+func pathType(t reflect.Type, path []string) reflect.Type {
+	for _, key := range path {
+		t = derefType(t)
+		for t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			t = derefType(t.Elem())
+		}
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		var next reflect.Type
+		for _, f := range jsonFields(t) {
+			if f.name == key {
+				next = f.typ
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		t = next
+	}
+	return derefType(t)
+}
+
+func flattensAsStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != reflect.TypeFor[time.Time]() &&
+		!reflect.PointerTo(t).Implements(reflect.TypeFor[json.Marshaler]())
+}
+
+type jsonField struct {
+	name string
+	typ  reflect.Type
+}
+
+// jsonFields lists the fields of t as encoding/json names them, in
+// order, with embedded structs inlined.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && derefType(f.Type).Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(derefType(f.Type))...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{name, f.Type})
+	}
+	return fields
+}
+
+// seenColumns lists the leaf columns of untyped rows in order of first
+// appearance.
+func seenColumns(items []any) []string {
+	var columns []string
+	seen := map[string]bool{}
+	for _, item := range items {
+		flatten("", item, func(key string) {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		})
+	}
+	return columns
+}
+
+func flatten(prefix string, v any, emit func(key string)) {
+	obj, ok := v.(orderedObject)
+	if !ok {
+		if prefix != "" && v != nil {
+			emit(prefix)
+		}
+		return
+	}
+	for _, f := range obj {
+		key := f.key
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		flatten(key, f.value, emit)
+	}
+}
+
+// cellAt formats the value at path in v. Arrays met along the path are
+// mapped over, so legs.origin lists every leg's origin.
+func cellAt(v any, path []string) string {
+	if len(path) == 0 {
+		return cellValue(v)
+	}
+	switch v := v.(type) {
+	case orderedObject:
+		for _, f := range v {
+			if f.key == path[0] {
+				return cellAt(f.value, path[1:])
+			}
+		}
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, e := range v {
+			parts = append(parts, cellAt(e, path))
+		}
+		return strings.Join(parts, "; ")
+	}
+	return ""
+}
+
+func cellValue(v any) string {
+	switch v := v.(type) {
+	case orderedObject:
+		return compactJSON(v)
+	case []any:
+		if hasObjects(v) {
+			return compactJSON(v)
+		}
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = scalar(e)
+		}
+		return strings.Join(parts, "; ")
+	}
+	return scalar(v)
+}
+
+func compactJSON(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// writeTSV writes records tab-separated. TSV has no quoting, so tabs and
+// newlines inside cells become spaces.
+func writeTSV(w *Writer, records [][]string) error {
+	clean := strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+	for _, rec := range records {
+		cells := make([]string, len(rec))
+		for i, c := range rec {
+			cells[i] = clean.Replace(c)
+		}
+		if _, err := fmt.Fprintln(w.Out, strings.Join(cells, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasObjects(list []any) bool {
+	for _, e := range list {
+		switch e.(type) {
+		case orderedObject, []any:
+			return true
+		}
+	}
+	return false
+}
+
+func scalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// decodeOrdered decodes one JSON value, keeping object key order.
+func decodeOrdered(dec *json.Decoder) (any, error) {
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := orderedObject{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			val, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, field{keyTok.(string), val})
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		list := []any{}
+		for dec.More() {
+			val, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, val)
+		}
+		_, err := dec.Token()
+		return list, err
+	}
+	return tok, nil
+}
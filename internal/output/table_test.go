@@ -0,0 +1,103 @@
+package output
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/*.golden")
+
+type testCard struct {
+	Type  string `json:"type"`
+	Class int    `json:"class"`
+}
+
+type testLeg struct {
+	Origin      string `json:"origin"`
+	Destination string `json:"destination"`
+}
+
+type testTrip struct {
+	ID        string            `json:"id"`
+	Departure time.Time         `json:"departure"`
+	Price     float64           `json:"price"`
+	Booked    bool              `json:"booked"`
+	Card      *testCard         `json:"card,omitempty"`
+	Tags      []string          `json:"tags,omitempty"`
+	Legs      []testLeg         `json:"legs,omitempty"`
+	Extra     map[string]string `json:"extra,omitempty"`
+	Note      string            `json:"note,omitempty"`
+	internal  string
+}
+
+var testTrips = []testTrip{
+	{
+		ID:        "t1",
+		Departure: time.Date(2026, 3, 1, 8, 15, 0, 0, time.UTC),
+		Price:     49.9,
+		Booked:    true,
+		Card:      &testCard{Type: "BC50", Class: 2},
+		Tags:      []string{"work", "ice"},
+		Legs:      []testLeg{{"Berlin Hbf", "Leipzig Hbf"}, {"Leipzig Hbf", "München Hbf"}},
+		Extra:     map[string]string{"seat": "42"},
+	},
+	{
+		ID:        "t2",
+		Departure: time.Date(2026, 3, 2, 17, 0, 0, 0, time.UTC),
+		Price:     19,
+	},
+}
+
+func TestWriteTableGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		fields []string
+		value  any
+	}{
+		// Columns come from the type: optional fields keep their column
+		// even when empty in every row.
+		{"list", FormatCSV, nil, testTrips},
+		{"list_tsv", FormatTSV, nil, []testTrip{{ID: "tab\there", Note: "line\nbreak"}}},
+		{"single", FormatCSV, nil, testTrips[0]},
+		{"empty_list", FormatCSV, nil, []testTrip{}},
+		{"optional_only_empty", FormatCSV, nil, testTrips[1:]},
+		// --fields order wins; a struct path expands, an array path maps.
+		{"fields", FormatCSV, []string{"legs.origin", "card", "id", "missing"}, testTrips},
+		// Rows sent through a Stream arrive as []any.
+		{"stream_items", FormatCSV, nil, []any{testTrips[1], testTrips[0]}},
+		// Untyped values fall back to columns in order of appearance.
+		{"untyped", FormatCSV, nil, []map[string]any{{"a": 1, "b": map[string]any{"c": true}}, {"a": 2, "d": nil}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := New(Options{Format: tt.format, Out: &out, Fields: tt.fields})
+			if err := w.Emit(tt.value, nil); err != nil {
+				t.Fatal(err)
+			}
+			golden(t, filepath.Join("testdata", "table_"+tt.name+".golden"), out.Bytes())
+		})
+	}
+}
+
+func golden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch:\n--- got\n%s\n--- want\n%s", path, got, want)
+	}
+}
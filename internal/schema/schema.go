@@ -0,0 +1,112 @@
+// Package schema derives JSON Schemas from Go payload types.
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Draft is the JSON Schema dialect emitted.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document. Keys marshal in sorted order, so
+// output is stable.
+type Schema map[string]any
+
+// For returns the schema of values of type t as encoding/json would
+// marshal them. Fields tagged omitempty or omitzero are optional, the
+// rest required; pointers may also be null.
+func For(t reflect.Type) Schema {
+	return forType(t, map[reflect.Type]bool{})
+}
+
+func forType(t reflect.Type, visiting map[reflect.Type]bool) Schema {
+	if t == reflect.TypeFor[time.Time]() {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+	if t.Implements(reflect.TypeFor[json.Marshaler]()) {
+		// Custom encodings can't be inferred.
+		return Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := forType(t.Elem(), visiting)
+		if typ, ok := s["type"].(string); ok {
+			s["type"] = []string{typ, "null"}
+		}
+		return s
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": forType(t.Elem(), visiting)}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": forType(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return Schema{"type": "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		return forStruct(t, visiting)
+	}
+	return Schema{}
+}
+
+func forStruct(t reflect.Type, visiting map[reflect.Type]bool) Schema {
+	props := Schema{}
+	required := []string{}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := range t.NumField() {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				walk(f.Type)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = forType(f.Type, visiting)
+			if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+				required = append(required, name)
+			}
+		}
+	}
+	walk(t)
+	return Schema{
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// Document returns s as a top-level schema with $schema and title.
+func Document(title string, s Schema) Schema {
+	doc := Schema{"$schema": Draft, "title": title}
+	for k, v := range s {
+		doc[k] = v
+	}
+	return doc
+}